@@ -0,0 +1,31 @@
+package main
+
+import "strings"
+
+// Separator between the alternative names listed in the aliases column of the Bertha sheet
+const aliasesSeparator = ";"
+
+// buildAliases merges the aliases derived from the author name with the ones curated in Bertha.
+// Aliases are trimmed and deduplicated, and variants of the prefLabel are left out.
+func buildAliases(prefLabel string, pn personName, curated string) []string {
+	candidates := []string{pn.fullName()}
+	candidates = append(candidates, strings.Split(curated, aliasesSeparator)...)
+
+	var aliases []string
+	seen := map[string]bool{aliasKey(prefLabel): true}
+	for _, c := range candidates {
+		alias := strings.Join(strings.Fields(c), " ")
+		key := aliasKey(alias)
+		if alias == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+// aliasKey considers two names the same regardless of case, dots and spacing, e.g. "Dr. J. Smith" and "dr j smith"
+func aliasKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.Replace(name, ".", " ", -1)), " "))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldAddNameWithoutSalutationAsAlias(t *testing.T) {
+	aliases := buildAliases("Sir Martin Wolf", parseName("Sir Martin Wolf"), "")
+	assert.Equal(t, []string{"Martin Wolf"}, aliases, "The alias should not contain the salutation")
+}
+
+func TestShouldNotAddAliasesWhenThereAreNoAlternativeNames(t *testing.T) {
+	aliases := buildAliases("Martin Wolf", parseName("Martin Wolf"), "")
+	assert.Nil(t, aliases, "There should be no aliases")
+}
+
+func TestShouldTrimAndDeduplicateCuratedAliases(t *testing.T) {
+	curated := " The Undercover Economist ;Tim  Harford; the undercover economist;;T. Harford "
+	aliases := buildAliases("Tim Harford", parseName("Tim Harford"), curated)
+	assert.Equal(t, []string{"The Undercover Economist", "T. Harford"}, aliases, "Aliases should be trimmed, deduplicated and exclude the prefLabel")
+}
+
+func TestShouldLeaveOutVariantsOfPrefLabel(t *testing.T) {
+	aliases := buildAliases("Dr. Lucy Kellaway", parseName("Dr. Lucy Kellaway"), "dr lucy kellaway;Lucy Kellaway")
+	assert.Equal(t, []string{"Lucy Kellaway"}, aliases, "Variants of the prefLabel should not be aliases")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/Financial-Times/go-fthealth/v1a"
 	"github.com/Financial-Times/http-handlers-go/httphandlers"
@@ -12,6 +13,9 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -30,47 +34,336 @@ func main() {
 		EnvVar: "BERTHA_SOURCE_URL",
 	})
 
+	biographyFormat := app.String(cli.StringOpt{
+		Name:   "biography-format",
+		Value:  biographyFormatHTML,
+		Desc:   "Default format of the author biographies: html, markdown or auto",
+		EnvVar: "BIOGRAPHY_FORMAT",
+	})
+	descriptionVariants := app.Bool(cli.BoolOpt{
+		Name:   "description-variants",
+		Value:  false,
+		Desc:   "Whether to add a summary and a link free plain text description to the authors",
+		EnvVar: "DESCRIPTION_VARIANTS",
+	})
+	summaryMaxLength := app.Int(cli.IntOpt{
+		Name:   "summary-max-length",
+		Value:  160,
+		Desc:   "Maximum number of characters of the author summary",
+		EnvVar: "SUMMARY_MAX_LENGTH",
+	})
+
+	publishImages := app.Bool(cli.BoolOpt{
+		Name:   "publish-images",
+		Value:  false,
+		Desc:   "Whether to expose the metadata of the author images and image sets",
+		EnvVar: "PUBLISH_IMAGES",
+	})
+
+	checkImages := app.Bool(cli.BoolOpt{
+		Name:   "check-images",
+		Value:  false,
+		Desc:   "Whether to verify the author image URLs on every refresh",
+		EnvVar: "CHECK_IMAGES",
+	})
+	imageCheckConcurrency := app.Int(cli.IntOpt{
+		Name:   "image-check-concurrency",
+		Value:  5,
+		Desc:   "Maximum number of image URLs verified at the same time",
+		EnvVar: "IMAGE_CHECK_CONCURRENCY",
+	})
+	imageCheckTimeout := app.Int(cli.IntOpt{
+		Name:   "image-check-timeout",
+		Value:  5,
+		Desc:   "Timeout in seconds of the verification of an image URL",
+		EnvVar: "IMAGE_CHECK_TIMEOUT",
+	})
+
+	minRefreshInterval := app.Int(cli.IntOpt{
+		Name:   "min-refresh-interval",
+		Value:  0,
+		Desc:   "Minimum number of seconds between two fetches of the authors from Bertha",
+		EnvVar: "MIN_REFRESH_INTERVAL",
+	})
+
+	berthaTimeout := app.Int(cli.IntOpt{
+		Name:   "bertha-timeout",
+		Value:  30,
+		Desc:   "Timeout in seconds of a call to Bertha",
+		EnvVar: "BERTHA_TIMEOUT",
+	})
+
+	authMode := app.String(cli.StringOpt{
+		Name:   "auth-mode",
+		Value:  authModeNone,
+		Desc:   "Authentication of the refresh and admin endpoints: none, api-key, hmac or basic",
+		EnvVar: "AUTH_MODE",
+	})
+	authKeys := app.String(cli.StringOpt{
+		Name:   "auth-keys",
+		Value:  "",
+		Desc:   "Comma separated name:secret keys allowed to call the refresh and admin endpoints",
+		EnvVar: "AUTH_KEYS",
+	})
+
+	restrictedFields := app.String(cli.StringOpt{
+		Name:   "restricted-fields",
+		Value:  "emailAddress",
+		Desc:   "Comma separated author fields only returned to the pii-reader-keys: emailAddress, twitterHandle, facebookProfile or linkedinProfile",
+		EnvVar: "RESTRICTED_FIELDS",
+	})
+	piiReaderKeys := app.String(cli.StringOpt{
+		Name:   "pii-reader-keys",
+		Value:  "",
+		Desc:   "Comma separated names of the auth-keys allowed to read the restricted fields",
+		EnvVar: "PII_READER_KEYS",
+	})
+
+	maxCacheAge := app.Int(cli.IntOpt{
+		Name:   "max-cache-age",
+		Value:  86400,
+		Desc:   "Maximum number of seconds since the last successful refresh before the cache is reported as stale, 0 to disable",
+		EnvVar: "MAX_CACHE_AGE",
+	})
+	maxCountDrop := app.Int(cli.IntOpt{
+		Name:   "max-count-drop",
+		Value:  20,
+		Desc:   "Maximum percentage of authors lost by a refresh before the health check fails, 0 to disable",
+		EnvVar: "MAX_COUNT_DROP",
+	})
+	maxTransformErrorRate := app.Int(cli.IntOpt{
+		Name:   "max-transform-error-rate",
+		Value:  0,
+		Desc:   "Maximum percentage of authors that cannot be transformed before the health check fails",
+		EnvVar: "MAX_TRANSFORM_ERROR_RATE",
+	})
+	maxBerthaLatency := app.Int(cli.IntOpt{
+		Name:   "max-bertha-latency",
+		Value:  5000,
+		Desc:   "Maximum number of milliseconds Bertha may take to answer before the health check fails, 0 to disable",
+		EnvVar: "MAX_BERTHA_LATENCY",
+	})
+
+	connectivityCheckInterval := app.Int(cli.IntOpt{
+		Name:   "connectivity-check-interval",
+		Value:  30,
+		Desc:   "Number of seconds between two background checks of the connectivity to Bertha",
+		EnvVar: "CONNECTIVITY_CHECK_INTERVAL",
+	})
+	maxConnectivityFailures := app.Int(cli.IntOpt{
+		Name:   "max-connectivity-failures",
+		Value:  3,
+		Desc:   "Number of consecutive failed connectivity checks to Bertha before the connectivity health check fails, 0 to fail on the first one",
+		EnvVar: "MAX_CONNECTIVITY_FAILURES",
+	})
+
+	tracingExporterKind := app.String(cli.StringOpt{
+		Name:   "tracing-exporter",
+		Value:  tracingExporterNone,
+		Desc:   "Exporter of the tracing spans: none, stdout or otlp",
+		EnvVar: "TRACING_EXPORTER",
+	})
+	otlpEndpoint := app.String(cli.StringOpt{
+		Name:   "otlp-endpoint",
+		Value:  "http://localhost:4318/v1/traces",
+		Desc:   "URL of the OTLP/HTTP traces endpoint of the OpenTelemetry collector",
+		EnvVar: "OTLP_ENDPOINT",
+	})
+
+	asyncInitialRefresh := app.Bool(cli.BoolOpt{
+		Name:   "async-initial-refresh",
+		Value:  false,
+		Desc:   "Serve HTTP before the first fetch of the authors completes, GTG failing until then",
+		EnvVar: "ASYNC_INITIAL_REFRESH",
+	})
+	shutdownDrainDelay := app.Int(cli.IntOpt{
+		Name:   "shutdown-drain-delay",
+		Value:  5,
+		Desc:   "Number of seconds GTG fails on SIGTERM before the server stops accepting connections",
+		EnvVar: "SHUTDOWN_DRAIN_DELAY",
+	})
+	shutdownTimeout := app.Int(cli.IntOpt{
+		Name:   "shutdown-timeout",
+		Value:  30,
+		Desc:   "Number of seconds the in-flight requests and refresh are given to finish on shutdown",
+		EnvVar: "SHUTDOWN_TIMEOUT",
+	})
+	snapshotFile := app.String(cli.StringOpt{
+		Name:   "snapshot-file",
+		Value:  "",
+		Desc:   "File the cached authors are written to as JSON on shutdown, none when empty",
+		EnvVar: "SNAPSHOT_FILE",
+	})
+
+	auditLogFile := app.String(cli.StringOpt{
+		Name:   "audit-log-file",
+		Value:  "",
+		Desc:   "File the refreshes are appended to as JSON lines, none when empty",
+		EnvVar: "AUDIT_LOG_FILE",
+	})
+	auditTailSize := app.Int(cli.IntOpt{
+		Name:   "audit-tail-size",
+		Value:  100,
+		Desc:   "Number of the last refreshes kept in memory for the audit endpoint",
+		EnvVar: "AUDIT_TAIL_SIZE",
+	})
+
 	app.Action = func() {
 		log.Info("App started!!!")
 
-		bs, err := newBerthaService(*berthaSrcUrl)
+		if !isValidBiographyFormat(*biographyFormat) {
+			err := fmt.Errorf("Unsupported biography format: %s", *biographyFormat)
+			log.Error(err)
+			panic(err)
+		}
+
+		if *connectivityCheckInterval <= 0 {
+			err := fmt.Errorf("The connectivity check interval must be positive: %d", *connectivityCheckInterval)
+			log.Error(err)
+			panic(err)
+		}
 
+		exporter, err := newSpanExporter(*tracingExporterKind, *otlpEndpoint)
 		if err != nil {
 			log.Error(err)
 			panic(err)
 		}
+		tracingExporter = exporter
 
-		ah := newAuthorHandler(bs)
+		auth, err := newAuthenticator(*authMode, *authKeys)
+		if err != nil {
+			log.Error(err)
+			panic(err)
+		}
 
-		h := setupServiceHandlers(ah)
+		vp, err := newVisibilityPolicy(*restrictedFields, auth, *piiReaderKeys)
+		if err != nil {
+			log.Error(err)
+			panic(err)
+		}
+
+		bt := &berthaTransformer{
+			biographyFormat:     *biographyFormat,
+			descriptionVariants: *descriptionVariants,
+			summaryMaxLength:    *summaryMaxLength,
+		}
+		var ic *imageChecker
+		if *checkImages {
+			ic = newImageChecker(*imageCheckConcurrency, time.Duration(*imageCheckTimeout)*time.Second)
+		}
+		al, err := newAuditLog(*auditLogFile, *auditTailSize)
+		if err != nil {
+			log.Error(err)
+			panic(err)
+		}
+
+		bs := newEmptyBerthaService(*berthaSrcUrl, bt, ic, time.Duration(*minRefreshInterval)*time.Second, time.Duration(*berthaTimeout)*time.Second)
+		bs.audit = al
+		if !*asyncInitialRefresh {
+			if err := bs.refreshCache(withTransactionID(context.Background(), newTransactionID())); err != nil {
+				log.Error(err)
+				panic(err)
+			}
+		}
+
+		hc := healthConfig{
+			maxCacheAge:           time.Duration(*maxCacheAge) * time.Second,
+			maxCountDropPercent:   float64(*maxCountDrop),
+			maxTransformErrorRate: float64(*maxTransformErrorRate) / 100,
+			maxBerthaLatency:      time.Duration(*maxBerthaLatency) * time.Millisecond,
+
+			maxConnectivityFailures: *maxConnectivityFailures,
+		}
+		stopMonitoring := make(chan struct{})
+		monitoringStopped := make(chan struct{})
+		go func() {
+			bs.monitorConnectivity(time.Duration(*connectivityCheckInterval)*time.Second, stopMonitoring)
+			close(monitoringStopped)
+		}()
+
+		ah := newAuthorHandler(bs, vp, hc)
+		if *asyncInitialRefresh {
+			go refreshUntilCached(ah.lifecycle, bs, time.Second, time.Minute)
+		}
+
+		h := setupServiceHandlers(ah, *publishImages, auth)
 
 		http.Handle("/", httphandlers.HTTPMetricsHandler(metrics.DefaultRegistry,
-			httphandlers.TransactionAwareRequestLoggingHandler(log.StandardLogger(), h)))
+			httphandlers.TransactionAwareRequestLoggingHandler(log.StandardLogger(), tracingHandler(h))))
+
+		server := &http.Server{Addr: fmt.Sprintf(":%d", *port)}
+		sc := shutdownConfig{
+			drainDelay:   time.Duration(*shutdownDrainDelay) * time.Second,
+			timeout:      time.Duration(*shutdownTimeout) * time.Second,
+			snapshotFile: *snapshotFile,
+		}
+		stopped := make(chan struct{})
+		go func() {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+			log.Infof("Received %v, shutting down", <-signals)
+			if err := shutdown(server, ah.lifecycle, bs, sc); err != nil {
+				log.Warnf("The shutdown was not graceful: %v", err)
+			}
+			close(stopMonitoring)
+			<-monitoringStopped
+			if tracingExporter != nil {
+				tracingExporter.shutdown()
+			}
+			if err := al.close(); err != nil {
+				log.Errorf("Cannot close the audit log: %v", err)
+			}
+			close(stopped)
+		}()
 
 		log.Infof("Listening on [%d].\n", *port)
-		errServe := http.ListenAndServe(fmt.Sprintf(":%d", *port), nil)
-		if errServe != nil {
+		if errServe := server.ListenAndServe(); errServe != http.ErrServerClosed {
 			log.Printf("Web server failed: [%v].\n", errServe)
+			return
 		}
+		<-stopped
+		log.Info("App stopped")
 	}
 
 	app.Run(os.Args)
 }
 
-func setupServiceHandlers(ah authorHandler) http.Handler {
+// setupServiceHandlers registers the routes of the service. The refresh and admin routes require authentication
+// unless the authenticator is nil.
+func setupServiceHandlers(ah authorHandler, publishImages bool, auth authenticator) http.Handler {
 	r := mux.NewRouter()
 
 	r.HandleFunc(status.PingPath, status.PingHandler)
 	r.HandleFunc(status.PingPathDW, status.PingHandler)
 	r.HandleFunc(status.BuildInfoPath, status.BuildInfoHandler)
 	r.HandleFunc(status.BuildInfoPathDW, status.BuildInfoHandler)
-	r.HandleFunc("/__health", v1a.Handler("Curated Authors Transformer", "Checks for accessing Bertha", ah.HealthCheck()))
+	r.HandleFunc("/__health", v1a.Handler("Curated Authors Transformer", "Checks for accessing Bertha and serving the curated authors",
+		ah.HealthCheck(), ah.CacheFreshnessCheck(), ah.AuthorsCountCheck(), ah.TransformErrorsCheck(), ah.BerthaLatencyCheck(), ah.ImagesHealthCheck()))
 	r.HandleFunc(status.GTGPath, ah.GoodToGo)
+	r.HandleFunc(apiPath, apiHandler(publishImages)).Methods("GET")
+	r.HandleFunc(metricsPath, prometheusHandler(metrics.DefaultRegistry)).Methods("GET")
 
-	r.HandleFunc("/transformers/authors", ah.refreshCache).Methods("POST")
-	r.HandleFunc("/transformers/authors/__count", ah.getAuthorsCount).Methods("GET")
-	r.HandleFunc("/transformers/authors/__ids", ah.getAuthorsUuids).Methods("GET")
-	r.HandleFunc("/transformers/authors/{uuid}", ah.getAuthorByUuid).Methods("GET")
+	// Unversioned routes serve the v1 representation for existing consumers
+	registerAuthorRoutes(r, "", ah, ah.getAuthorByUuid, publishImages, auth)
+	registerAuthorRoutes(r, "/v1", ah, ah.getAuthorByUuid, publishImages, auth)
+	registerAuthorRoutes(r, "/v2", ah, ah.getAuthorByUuidV2, publishImages, auth)
 
 	return r
 }
+
+func registerAuthorRoutes(r *mux.Router, versionPrefix string, ah authorHandler, getAuthorByUuid http.HandlerFunc, publishImages bool, auth authenticator) {
+	r.HandleFunc(versionPrefix+"/transformers/authors", requireAuth(auth, ah.refreshCache)).Methods("POST")
+	r.HandleFunc(versionPrefix+"/transformers/authors/__count", authenticatedOr(auth, ah.getAuthorsCount, ah.countCachedAuthors)).Methods("GET")
+	r.HandleFunc(versionPrefix+"/transformers/authors/__ids", ah.getAuthorsUuids).Methods("GET")
+	r.HandleFunc(versionPrefix+"/transformers/authors/__images", requireAuth(auth, ah.getImageStatuses)).Methods("GET")
+	r.HandleFunc(versionPrefix+"/transformers/authors/__audit", requireAuth(auth, ah.getAuditTail)).Methods("GET")
+	r.HandleFunc(versionPrefix+"/transformers/authors/__export.ttl", ah.exportTurtle).Methods("GET")
+	r.HandleFunc(versionPrefix+"/transformers/authors/__export.nt", ah.exportNTriples).Methods("GET")
+	r.HandleFunc(versionPrefix+"/transformers/authors/__export.csv", ah.exportCSV).Methods("GET")
+	r.HandleFunc(versionPrefix+"/transformers/authors/{uuid}", getAuthorByUuid).Methods("GET")
+
+	if publishImages {
+		r.HandleFunc(versionPrefix+"/transformers/author-images/__ids", ah.getImagesUuids).Methods("GET")
+		r.HandleFunc(versionPrefix+"/transformers/author-images/{uuid}", ah.getImageByUuid).Methods("GET")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// auditEntry records a refresh of the cached authors: who triggered it, what Bertha answered and how the authors changed.
+// The refreshes requested while a fetch is in flight share it, so only the caller that started the fetch is recorded.
+type auditEntry struct {
+	Time           time.Time `json:"time"`
+	TransactionID  string    `json:"transactionId,omitempty"`
+	Caller         string    `json:"caller,omitempty"`
+	SourceURL      string    `json:"sourceUrl,omitempty"`
+	UpstreamStatus int       `json:"upstreamStatus,omitempty"`
+	ETag           string    `json:"etag,omitempty"`
+	FromCache      bool      `json:"fromCache"`
+	AuthorsBefore  int       `json:"authorsBefore"`
+	AuthorsAfter   int       `json:"authorsAfter"`
+	Diff           auditDiff `json:"diff"`
+	DurationMillis int64     `json:"durationMillis"`
+	Error          string    `json:"error,omitempty"`
+
+	publicError string
+}
+
+// redacted returns the entry as served over HTTP, without the URL of Bertha that its errors may contain
+func (e auditEntry) redacted() auditEntry {
+	e.SourceURL = ""
+	e.Error = e.publicError
+	return e
+}
+
+// publicErrorMessage describes the failure of a refresh to the clients, like the error responses do
+func publicErrorMessage(err error) string {
+	if e, ok := err.(*upstreamError); ok {
+		return e.message()
+	}
+	return err.Error()
+}
+
+// auditDiff summarises the changes of a refresh, naming the removed authors
+type auditDiff struct {
+	Added          int           `json:"added"`
+	Changed        int           `json:"changed"`
+	Removed        int           `json:"removed"`
+	RemovedAuthors []auditAuthor `json:"removedAuthors,omitempty"`
+}
+
+type auditAuthor struct {
+	UUID      string `json:"uuid"`
+	PrefLabel string `json:"prefLabel"`
+}
+
+// auditLog appends the refreshes as JSON lines to a file, when there is one, and keeps the last ones in memory
+type auditLog struct {
+	file     *os.File
+	entries  []auditEntry
+	tailSize int
+	mutex    sync.Mutex
+}
+
+// newAuditLog opens the file of the audit log, which is only kept in memory when the path is empty
+func newAuditLog(path string, tailSize int) (*auditLog, error) {
+	if tailSize < 0 {
+		return nil, fmt.Errorf("The audit tail size cannot be negative: %d", tailSize)
+	}
+	al := &auditLog{tailSize: tailSize}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		al.file = f
+	}
+	return al, nil
+}
+
+// record appends an entry to the audit log. A nil audit log records nothing.
+func (al *auditLog) record(e auditEntry) {
+	if al == nil {
+		return
+	}
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+	if al.file != nil {
+		line, err := json.Marshal(e)
+		if err == nil {
+			_, err = al.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			log.Errorf("Cannot write the refresh %s to the audit log: %v", e.TransactionID, err)
+		}
+	}
+	if al.entries = append(al.entries, e); len(al.entries) > al.tailSize {
+		al.entries = al.entries[len(al.entries)-al.tailSize:]
+	}
+}
+
+// tail returns at most the last n entries kept in memory, oldest first
+func (al *auditLog) tail(n int) []auditEntry {
+	if al == nil {
+		return []auditEntry{}
+	}
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+	if n > len(al.entries) || n < 0 {
+		n = len(al.entries)
+	}
+	entries := make([]auditEntry, n)
+	copy(entries, al.entries[len(al.entries)-n:])
+	return entries
+}
+
+func (al *auditLog) close() error {
+	if al == nil || al.file == nil {
+		return nil
+	}
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+	return al.file.Close()
+}
+
+// newAuditEntry starts the record of a refresh with the transaction id and the caller of the context
+func newAuditEntry(ctx context.Context, sourceURL string) auditEntry {
+	return auditEntry{
+		Time:          time.Now().UTC(),
+		TransactionID: transactionID(ctx),
+		Caller:        caller(ctx),
+		SourceURL:     sourceURL,
+	}
+}
+
+// diffAuthors compares the cached authors before and after a refresh
+func diffAuthors(before map[string]person, after map[string]person) auditDiff {
+	var d auditDiff
+	for id, p := range after {
+		if old, found := before[id]; !found {
+			d.Added++
+		} else if !reflect.DeepEqual(old, p) {
+			d.Changed++
+		}
+	}
+	for id, p := range before {
+		if _, found := after[id]; !found {
+			d.Removed++
+			d.RemovedAuthors = append(d.RemovedAuthors, auditAuthor{UUID: id, PrefLabel: p.PrefLabel})
+		}
+	}
+	sort.Sort(byAuditUUID(d.RemovedAuthors))
+	return d
+}
+
+type byAuditUUID []auditAuthor
+
+func (a byAuditUUID) Len() int           { return len(a) }
+func (a byAuditUUID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAuditUUID) Less(i, j int) bool { return a[i].UUID < a[j].UUID }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldAuditRefreshes(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	dir, _ := ioutil.TempDir("", "audit")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	al, err := newAuditLog(path, 10)
+	assert.Nil(t, err)
+
+	bs := newEmptyBerthaService(berthaMock.URL+berthaPath, &berthaTransformer{}, nil, 0, 0)
+	bs.audit = al
+	ctx := withCaller(withTransactionID(context.Background(), "tid_audit"), "publishing")
+	assert.Nil(t, bs.refreshCache(ctx))
+	assert.Nil(t, bs.refreshCache(context.Background()))
+	assert.Nil(t, al.close())
+
+	entries := bs.getAuditTail(context.Background(), -1)
+	assert.Len(t, entries, 2, "Every refresh should be audited")
+	first := entries[0]
+	assert.Equal(t, "tid_audit", first.TransactionID)
+	assert.Equal(t, "publishing", first.Caller, "The caller should be recorded")
+	assert.Equal(t, berthaMock.URL+berthaPath, first.SourceURL)
+	assert.Equal(t, http.StatusOK, first.UpstreamStatus)
+	assert.Equal(t, etag, first.ETag)
+	assert.Equal(t, 0, first.AuthorsBefore)
+	assert.Equal(t, 2, first.AuthorsAfter)
+	assert.Equal(t, auditDiff{Added: 2}, first.Diff)
+	assert.Empty(t, first.Error)
+	assert.True(t, entries[1].FromCache, "A revalidated response should be recorded as cached")
+	assert.Equal(t, auditDiff{}, entries[1].Diff, "Unchanged authors should not be counted")
+
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	var lines []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &e), "Every line should be a JSON entry")
+		lines = append(lines, e)
+	}
+	assert.Len(t, lines, 2, "Every refresh should be appended to the file")
+	assert.Equal(t, "publishing", lines[0].Caller)
+}
+
+func TestShouldAuditFailedRefresh(t *testing.T) {
+	startBerthaMock("happy")
+	bs, err := newBerthaService(berthaMock.URL+berthaPath, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+	berthaMock.Close()
+	startBerthaMock("unhappy")
+	defer berthaMock.Close()
+	bs.berthaUrl = berthaMock.URL + berthaPath
+	bs.audit, _ = newAuditLog("", 10)
+
+	assert.NotNil(t, bs.refreshCache(context.Background()))
+
+	entries := bs.getAuditTail(context.Background(), 1)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, http.StatusInternalServerError, entries[0].UpstreamStatus)
+	assert.Equal(t, 2, entries[0].AuthorsBefore)
+	assert.Equal(t, 2, entries[0].AuthorsAfter, "The cached authors should be kept")
+	assert.NotEmpty(t, entries[0].Error, "The error should be recorded")
+}
+
+func TestShouldDiffAuthors(t *testing.T) {
+	changedMartinWolf := transformedMartinWolf
+	changedMartinWolf.Role = "Chief Economics Commentator"
+	before := map[string]person{martinWolfUuid: transformedMartinWolf, lucyKellawayUuid: {Uuid: lucyKellawayUuid, PrefLabel: "Lucy Kellaway"}}
+	after := map[string]person{martinWolfUuid: changedMartinWolf, "daf5fed2-013c-468d-85c4-aee779b8aa51": {}}
+
+	d := diffAuthors(before, after)
+
+	assert.Equal(t, 1, d.Added)
+	assert.Equal(t, 1, d.Changed)
+	assert.Equal(t, 1, d.Removed)
+	assert.Equal(t, []auditAuthor{{UUID: lucyKellawayUuid, PrefLabel: "Lucy Kellaway"}}, d.RemovedAuthors, "The removed authors should be named")
+}
+
+func TestShouldKeepLastAuditEntries(t *testing.T) {
+	al, _ := newAuditLog("", 2)
+	for _, tid := range []string{"tid_1", "tid_2", "tid_3"} {
+		al.record(auditEntry{TransactionID: tid})
+	}
+
+	assert.Equal(t, []auditEntry{{TransactionID: "tid_2"}, {TransactionID: "tid_3"}}, al.tail(-1), "Only the last entries should be kept")
+	assert.Equal(t, []auditEntry{{TransactionID: "tid_3"}}, al.tail(1))
+	assert.Empty(t, (*auditLog)(nil).tail(1), "A nil audit log should have no entries")
+}
+
+func TestShouldRejectNegativeAuditTailSize(t *testing.T) {
+	al, err := newAuditLog("", -1)
+
+	assert.NotNil(t, err, "A negative tail size should be rejected")
+	assert.Nil(t, al)
+}
+
+func TestShouldServeAuditTail(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	failure := &upstreamError{url: "http://bertha/Authors", err: errors.New(`Get "http://bertha/Authors": dial tcp: connection refused`)}
+	mbs.On("getAuditTail", 5).Return([]auditEntry{{TransactionID: "tid_5", SourceURL: failure.url, Error: failure.Error(), publicError: publicErrorMessage(failure)}})
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/__audit?limit=5")
+	assert.Nil(t, err)
+	var entries []auditEntry
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&entries))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "tid_5", entries[0].TransactionID)
+	assert.Empty(t, entries[0].SourceURL, "The URL of Bertha should be left out")
+	assert.Equal(t, "Bertha is unreachable", entries[0].Error, "The error of Bertha should be described without its URL")
+
+	resp, err = http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/__audit?limit=five")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "An invalid limit should be rejected")
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	authModeNone   = "none"
+	authModeAPIKey = "api-key"
+	authModeHMAC   = "hmac"
+	authModeBasic  = "basic"
+)
+
+const (
+	apiKeyHeader          = "X-Api-Key"
+	keyIdHeader           = "X-Key-Id"
+	timestampHeader       = "X-Timestamp"
+	nonceHeader           = "X-Nonce"
+	signatureHeader       = "X-Signature"
+	maxSignatureClockSkew = 5 * time.Minute
+)
+
+var errMissingCredentials = errors.New("Missing credentials")
+var errInvalidCredentials = errors.New("Invalid credentials")
+
+// authenticator identifies the caller of a request, returning the name of the key the request has been authenticated with
+type authenticator interface {
+	authenticate(req *http.Request) (string, error)
+}
+
+// newAuthenticator creates the authenticator of a mode from a comma separated list of name:secret keys.
+// It returns nil when authentication is disabled.
+func newAuthenticator(mode string, keys string) (authenticator, error) {
+	if mode == authModeNone {
+		return nil, nil
+	}
+
+	secrets, err := parseKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("No keys configured for %s authentication", mode)
+	}
+
+	switch mode {
+	case authModeAPIKey:
+		return &apiKeyAuthenticator{secrets: secrets}, nil
+	case authModeHMAC:
+		return &hmacAuthenticator{secrets: secrets, now: time.Now, nonces: map[string]time.Time{}}, nil
+	case authModeBasic:
+		return &basicAuthenticator{secrets: secrets}, nil
+	}
+	return nil, fmt.Errorf("Unsupported authentication mode: %s", mode)
+}
+
+// parseKeys maps the names of the keys to their secrets
+func parseKeys(keys string) (map[string]string, error) {
+	secrets := map[string]string{}
+	for _, k := range strings.Split(keys, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		parts := strings.SplitN(k, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("Malformed key, expected name:secret")
+		}
+		secrets[parts[0]] = parts[1]
+	}
+	return secrets, nil
+}
+
+// apiKeyAuthenticator accepts requests with one of the configured secrets in the X-Api-Key header
+type apiKeyAuthenticator struct {
+	secrets map[string]string
+}
+
+func (a *apiKeyAuthenticator) authenticate(req *http.Request) (string, error) {
+	key := req.Header.Get(apiKeyHeader)
+	if key == "" {
+		return "", errMissingCredentials
+	}
+	for name, secret := range a.secrets {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(secret)) == 1 {
+			return name, nil
+		}
+	}
+	return "", errInvalidCredentials
+}
+
+// hmacAuthenticator accepts requests signed with the secret of the key in the X-Key-Id header.
+// X-Signature is the hex encoded HMAC-SHA256 of the method, the path, the raw query, the X-Timestamp Unix time,
+// the X-Nonce and the hex encoded SHA-256 of the body separated by new lines. A nonce is accepted only once.
+type hmacAuthenticator struct {
+	secrets map[string]string
+	now     func() time.Time
+	nonces  map[string]time.Time
+	mutex   sync.Mutex
+}
+
+func (a *hmacAuthenticator) authenticate(req *http.Request) (string, error) {
+	name := req.Header.Get(keyIdHeader)
+	timestamp := req.Header.Get(timestampHeader)
+	nonce := req.Header.Get(nonceHeader)
+	signature := req.Header.Get(signatureHeader)
+	if name == "" || timestamp == "" || nonce == "" || signature == "" {
+		return "", errMissingCredentials
+	}
+
+	secret, found := a.secrets[name]
+	if !found {
+		return name, errInvalidCredentials
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return name, errInvalidCredentials
+	}
+	skew := a.now().Sub(time.Unix(seconds, 0))
+	if skew > maxSignatureClockSkew || skew < -maxSignatureClockSkew {
+		return name, errors.New("Expired signature")
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return name, err
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(actual, signRequest(secret, req.Method, req.URL.Path, req.URL.RawQuery, timestamp, nonce, body)) {
+		return name, errInvalidCredentials
+	}
+	if !a.useNonce(name + ":" + nonce) {
+		return name, errors.New("Replayed signature")
+	}
+	return name, nil
+}
+
+// useNonce remembers the nonces for as long as their signatures are valid, returning false for a nonce seen already
+func (a *hmacAuthenticator) useNonce(nonce string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := a.now()
+	for n, expiry := range a.nonces {
+		if now.After(expiry) {
+			delete(a.nonces, n)
+		}
+	}
+	if _, seen := a.nonces[nonce]; seen {
+		return false
+	}
+	a.nonces[nonce] = now.Add(2 * maxSignatureClockSkew)
+	return true
+}
+
+// readBody reads the body of the request, leaving it to be read again by the handler
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, err
+}
+
+func signRequest(secret string, method string, path string, query string, timestamp string, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{method, path, query, timestamp, nonce, hex.EncodeToString(bodyHash[:])}, "\n")))
+	return mac.Sum(nil)
+}
+
+// basicAuthenticator accepts requests with the name and the secret of a key as basic auth credentials
+type basicAuthenticator struct {
+	secrets map[string]string
+}
+
+func (a *basicAuthenticator) authenticate(req *http.Request) (string, error) {
+	name, password, ok := req.BasicAuth()
+	if !ok {
+		return "", errMissingCredentials
+	}
+	secret, found := a.secrets[name]
+	if !found || subtle.ConstantTimeCompare([]byte(password), []byte(secret)) != 1 {
+		return name, errInvalidCredentials
+	}
+	return name, nil
+}
+
+// requireAuth lets only authenticated requests through to the handler, logging who called it.
+// Every request goes through when the authenticator is nil.
+func requireAuth(a authenticator, next http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return next
+	}
+	return func(writer http.ResponseWriter, req *http.Request) {
+		key, err := a.authenticate(req)
+		entry := authLogEntry(req, key)
+		if err != nil {
+			entry.WithField("error", err.Error()).Warn("Rejected unauthenticated request")
+			if _, basic := a.(*basicAuthenticator); basic {
+				writer.Header().Set("WWW-Authenticate", `Basic realm="curated-authors-transformer"`)
+			}
+			writeJSONError(writer, req, http.StatusUnauthorized, errorCodeUnauthorized, err.Error(), nil)
+			return
+		}
+		entry.Info("Authenticated request")
+		next(writer, req.WithContext(withCaller(req.Context(), key)))
+	}
+}
+
+// authenticatedOr passes the authenticated requests to the handler and the other ones to the fallback.
+// Every request goes to the handler when the authenticator is nil.
+func authenticatedOr(a authenticator, next http.HandlerFunc, fallback http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return next
+	}
+	return func(writer http.ResponseWriter, req *http.Request) {
+		key, err := a.authenticate(req)
+		if err != nil {
+			fallback(writer, req)
+			return
+		}
+		authLogEntry(req, key).Info("Authenticated request")
+		next(writer, req.WithContext(withCaller(req.Context(), key)))
+	}
+}
+
+func authLogEntry(req *http.Request, key string) *log.Entry {
+	return log.WithFields(log.Fields{
+		"key":            key,
+		"method":         req.Method,
+		"path":           req.URL.Path,
+		"remote_addr":    req.RemoteAddr,
+		"transaction_id": transactionID(req.Context()),
+	})
+}
+
+// withCaller keeps the name of the key authenticating a request, to record who triggered a refresh
+func withCaller(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, callerContextKey, key)
+}
+
+func caller(ctx context.Context) string {
+	key, _ := ctx.Value(callerContextKey).(string)
+	return key
+}
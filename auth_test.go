@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var authKeys = "publishing:s3cr3t,ops:0th3r"
+
+func TestShouldAuthenticateWithAPIKey(t *testing.T) {
+	a, err := newAuthenticator(authModeAPIKey, authKeys)
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest("POST", "/transformers/authors", nil)
+	req.Header.Set(apiKeyHeader, "0th3r")
+	key, err := a.authenticate(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "ops", key, "The request should be authenticated with the ops key")
+
+	req.Header.Set(apiKeyHeader, "guess")
+	_, err = a.authenticate(req)
+	assert.Equal(t, errInvalidCredentials, err, "An unknown key should be rejected")
+
+	req.Header.Del(apiKeyHeader)
+	_, err = a.authenticate(req)
+	assert.Equal(t, errMissingCredentials, err, "A request without key should be rejected")
+}
+
+func TestShouldAuthenticateWithHMACSignature(t *testing.T) {
+	now := time.Unix(1476871200, 0)
+	a := &hmacAuthenticator{secrets: map[string]string{"publishing": "s3cr3t"}, now: func() time.Time { return now }, nonces: map[string]time.Time{}}
+
+	var tests = []struct {
+		timestamp time.Time
+		secret    string
+		path      string
+		query     string
+		body      string
+		valid     bool
+	}{
+		{now, "s3cr3t", "/transformers/authors", "limit=5", "{}", true},
+		{now.Add(-time.Minute), "s3cr3t", "/transformers/authors", "limit=5", "{}", true},
+		{now, "guess", "/transformers/authors", "limit=5", "{}", false},
+		{now, "s3cr3t", "/v1/transformers/authors", "limit=5", "{}", false},
+		{now, "s3cr3t", "/transformers/authors", "limit=500", "{}", false},
+		{now, "s3cr3t", "/transformers/authors", "limit=5", "", false},
+		{now.Add(-10 * time.Minute), "s3cr3t", "/transformers/authors", "limit=5", "{}", false},
+	}
+
+	for i, test := range tests {
+		ts := strconv.FormatInt(test.timestamp.Unix(), 10)
+		nonce := strconv.Itoa(i)
+		req := httptest.NewRequest("POST", "/transformers/authors?limit=5", strings.NewReader("{}"))
+		req.Header.Set(keyIdHeader, "publishing")
+		req.Header.Set(timestampHeader, ts)
+		req.Header.Set(nonceHeader, nonce)
+		req.Header.Set(signatureHeader, hex.EncodeToString(signRequest(test.secret, "POST", test.path, test.query, ts, nonce, []byte(test.body))))
+
+		key, err := a.authenticate(req)
+		assert.Equal(t, "publishing", key, "The key should be identified")
+		assert.Equal(t, test.valid, err == nil, "Unexpected outcome of the signature %+v", test)
+		if test.valid {
+			body, _ := ioutil.ReadAll(req.Body)
+			assert.Equal(t, "{}", string(body), "The body should be left to the handler")
+		}
+	}
+}
+
+func TestShouldRejectReplayedHMACSignature(t *testing.T) {
+	now := time.Unix(1476871200, 0)
+	a := &hmacAuthenticator{secrets: map[string]string{"publishing": "s3cr3t"}, now: func() time.Time { return now }, nonces: map[string]time.Time{}}
+	signed := func(nonce string) *http.Request {
+		ts := strconv.FormatInt(now.Unix(), 10)
+		req := httptest.NewRequest("POST", "/transformers/authors", nil)
+		req.Header.Set(keyIdHeader, "publishing")
+		req.Header.Set(timestampHeader, ts)
+		req.Header.Set(nonceHeader, nonce)
+		req.Header.Set(signatureHeader, hex.EncodeToString(signRequest("s3cr3t", "POST", "/transformers/authors", "", ts, nonce, nil)))
+		return req
+	}
+
+	_, err := a.authenticate(signed("n0nc3"))
+	assert.Nil(t, err)
+	_, err = a.authenticate(signed("n0nc3"))
+	assert.NotNil(t, err, "A signature should be accepted only once")
+
+	now = now.Add(11 * time.Minute)
+	_, err = a.authenticate(signed("0th3r"))
+	assert.Nil(t, err)
+	assert.Len(t, a.nonces, 1, "The expired nonces should be forgotten")
+}
+
+func TestShouldAuthenticateWithBasicAuth(t *testing.T) {
+	a, err := newAuthenticator(authModeBasic, authKeys)
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest("POST", "/transformers/authors", nil)
+	req.SetBasicAuth("publishing", "s3cr3t")
+	key, err := a.authenticate(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "publishing", key, "The request should be authenticated with the publishing key")
+
+	req.SetBasicAuth("publishing", "0th3r")
+	_, err = a.authenticate(req)
+	assert.Equal(t, errInvalidCredentials, err, "The secret of another key should be rejected")
+}
+
+func TestShouldNotCreateAuthenticatorWithoutKeys(t *testing.T) {
+	a, err := newAuthenticator(authModeNone, "")
+	assert.Nil(t, err)
+	assert.Nil(t, a, "There should be no authenticator when authentication is disabled")
+
+	_, err = newAuthenticator(authModeAPIKey, "")
+	assert.NotNil(t, err, "Keys should be required")
+
+	_, err = newAuthenticator(authModeAPIKey, "s3cr3t")
+	assert.NotNil(t, err, "Keys without name should be rejected")
+
+	_, err = newAuthenticator("oauth", authKeys)
+	assert.NotNil(t, err, "Unsupported modes should be rejected")
+}
+
+func TestShouldReturn401WhenRefreshIsNotAuthenticated(t *testing.T) {
+	a, _ := newAuthenticator(authModeBasic, authKeys)
+	mbs := new(MockedBerthaService)
+	mbs.On("refreshCache").Return(nil)
+	server := httptest.NewServer(setupServiceHandlers(newAuthorHandler(mbs, nil, healthConfig{}), false, a))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/transformers/authors", "application/json", nil)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "Response status should be 401")
+	assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"), "Basic auth should be challenged")
+	mbs.AssertNotCalled(t, "refreshCache")
+
+	req, _ := http.NewRequest("POST", server.URL+"/transformers/authors", nil)
+	req.SetBasicAuth("ops", "0th3r")
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	mbs.AssertCalled(t, "refreshCache")
+}
+
+func TestShouldPassCallerOfAuthenticatedRequest(t *testing.T) {
+	a, _ := newAuthenticator(authModeAPIKey, authKeys)
+	var key string
+	h := requireAuth(a, func(writer http.ResponseWriter, req *http.Request) {
+		key = caller(req.Context())
+	})
+
+	req := httptest.NewRequest("POST", "/transformers/authors", nil)
+	req.Header.Set(apiKeyHeader, "s3cr3t")
+	h(httptest.NewRecorder(), req)
+	assert.Equal(t, "publishing", key, "The name of the key should be the caller")
+}
+
+func TestShouldRefreshAuthorsOnCountOnlyWhenAuthenticated(t *testing.T) {
+	a, _ := newAuthenticator(authModeAPIKey, authKeys)
+	mbs := new(MockedBerthaService)
+	mbs.On("refreshCache").Return(nil)
+	mbs.On("getAuthorsCount").Return(2)
+	server := httptest.NewServer(setupServiceHandlers(newAuthorHandler(mbs, nil, healthConfig{}), false, a))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/transformers/authors/__count")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Unauthenticated callers should get the cached count")
+	mbs.AssertNotCalled(t, "refreshCache")
+
+	req, _ := http.NewRequest("GET", server.URL+"/transformers/authors/__count", nil)
+	req.Header.Set(apiKeyHeader, "s3cr3t")
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	mbs.AssertCalled(t, "refreshCache")
+}
+
+func TestShouldNotRequireAuthenticationToReadAuthors(t *testing.T) {
+	a, _ := newAuthenticator(authModeAPIKey, authKeys)
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorsUuids").Return([]string{martinWolfUuid})
+	server := httptest.NewServer(setupServiceHandlers(newAuthorHandler(mbs, nil, healthConfig{}), false, a))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/transformers/authors/__ids")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+}
@@ -1,13 +1,50 @@
 package main
 
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Prefix of the Bertha columns holding the biography in other languages, e.g. biography_zh
+const localisedBiographyPrefix = "biography_"
+
 // This struct reflects the JSON data model of curated authors from Bertha
 type author struct {
-	Name            string `json:"name"`
-	Email           string `json:"email"`
-	ImageUrl        string `json:"imageurl"`
-	Biography       string `json:"biography"`
-	TwitterHandle   string `json:"twitterhandle"`
-	FacebookProfile string `json:"facebookprofile"`
-	LinkedinProfile string `json:"linkedinprofile"`
-	TmeIdentifier   string `json:"tmeidentifier"`
+	Name                 string            `json:"name"`
+	Role                 string            `json:"role"`
+	Email                string            `json:"email"`
+	ImageUrl             string            `json:"imageurl"`
+	Biography            string            `json:"biography"`
+	BiographyFormat      string            `json:"biographyformat"`
+	LocalisedBiographies map[string]string `json:"-"`
+	TwitterHandle        string            `json:"twitterhandle"`
+	FacebookProfile      string            `json:"facebookprofile"`
+	LinkedinProfile      string            `json:"linkedinprofile"`
+	TmeIdentifier        string            `json:"tmeidentifier"`
+	Aliases              string            `json:"aliases"`
+}
+
+// UnmarshalJSON collects the localised biography columns, keyed by BCP 47 language tag, alongside the other columns
+func (a *author) UnmarshalJSON(data []byte) error {
+	type columns author
+	if err := json.Unmarshal(data, (*columns)(a)); err != nil {
+		return err
+	}
+
+	var row map[string]interface{}
+	if err := json.Unmarshal(data, &row); err != nil {
+		return err
+	}
+	for column, value := range row {
+		biography, isString := value.(string)
+		if !strings.HasPrefix(column, localisedBiographyPrefix) || !isString || strings.TrimSpace(biography) == "" {
+			continue
+		}
+		if a.LocalisedBiographies == nil {
+			a.LocalisedBiographies = map[string]string{}
+		}
+		lang := languageTag(strings.TrimPrefix(column, localisedBiographyPrefix))
+		a.LocalisedBiographies[lang] = biography
+	}
+	return nil
 }
@@ -2,104 +2,325 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Financial-Times/go-fthealth/v1a"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
 	"net/http"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 type authorHandler struct {
 	authorsService authorsService
+	visibility     *visibilityPolicy
+	health         healthConfig
+	lifecycle      *lifecycle
 }
 
-func newAuthorHandler(as authorsService) authorHandler {
+// newAuthorHandler creates the handler of the author routes. All the fields of the authors are visible
+// when the visibility policy is nil.
+func newAuthorHandler(as authorsService, vp *visibilityPolicy, hc healthConfig) authorHandler {
 	return authorHandler{
 		authorsService: as,
+		visibility:     vp,
+		health:         hc,
+		lifecycle:      &lifecycle{},
 	}
 }
 
 func (ah *authorHandler) refreshCache(writer http.ResponseWriter, req *http.Request) {
-	err := ah.authorsService.refreshCache()
+	err := ah.authorsService.refreshCache(req.Context())
 	if err != nil {
-		writeJSONMessage(writer, err.Error(), http.StatusInternalServerError)
+		writeServiceError(writer, req, err)
 	} else {
 		writeJSONMessage(writer, "Authors fetched", http.StatusOK)
 	}
 }
 
+// getAuthorsCount refreshes the authors before counting them. The cached authors are counted
+// when they have just been refreshed.
 func (ah *authorHandler) getAuthorsCount(writer http.ResponseWriter, req *http.Request) {
-	err := ah.authorsService.refreshCache()
-	if err != nil {
-		writeJSONMessage(writer, err.Error(), http.StatusInternalServerError)
+	err := ah.authorsService.refreshCache(req.Context())
+	if _, throttled := err.(*refreshThrottledError); err != nil && !throttled {
+		writeServiceError(writer, req, err)
 	} else {
-		c := ah.authorsService.getAuthorsCount()
-		var buffer bytes.Buffer
-		buffer.WriteString(fmt.Sprintf(`%v`, c))
-		buffer.WriteTo(writer)
+		ah.countCachedAuthors(writer, req)
 	}
 }
 
+// countCachedAuthors counts the cached authors without refreshing them, for the callers that cannot trigger a refresh
+func (ah *authorHandler) countCachedAuthors(writer http.ResponseWriter, req *http.Request) {
+	c := ah.authorsService.getAuthorsCount(req.Context())
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf(`%v`, c))
+	buffer.WriteTo(writer)
+}
+
 func (ah *authorHandler) getAuthorsUuids(writer http.ResponseWriter, req *http.Request) {
-	uuids := ah.authorsService.getAuthorsUuids()
+	uuids := ah.authorsService.getAuthorsUuids(req.Context())
 	writeStreamResponse(uuids, writer)
 }
 
 func (ah *authorHandler) getAuthorByUuid(writer http.ResponseWriter, req *http.Request) {
+	ah.writeAuthor(writer, req, serializePersonV1)
+}
+
+func (ah *authorHandler) getAuthorByUuidV2(writer http.ResponseWriter, req *http.Request) {
+	ah.writeAuthor(writer, req, serializePersonV2)
+}
+
+func (ah *authorHandler) writeAuthor(writer http.ResponseWriter, req *http.Request, serialize personSerializer) {
+	vars := mux.Vars(req)
+	requestedUuid := vars["uuid"]
+
+	parsedUuid := uuid.Parse(requestedUuid)
+	if parsedUuid == nil {
+		writeJSONError(writer, req, http.StatusBadRequest, errorCodeInvalidUuid, "Invalid author UUID", map[string]string{"uuid": requestedUuid})
+		return
+	}
+	id := parsedUuid.String()
+
+	a := ah.authorsService.getAuthorByUuid(req.Context(), id)
+	found := !reflect.DeepEqual(a, person{})
+
+	writer.Header().Add("Vary", "Accept")
+	writer.Header().Add("Vary", "Accept-Language")
+	if !found {
+		writeJSONError(writer, req, http.StatusNotFound, errorCodeNotFound, "Author not found", map[string]string{"uuid": requestedUuid})
+		return
+	}
+	if a.Uuid != id {
+		canonicalUrl := *req.URL
+		canonicalUrl.Path = strings.TrimSuffix(req.URL.Path, requestedUuid) + a.Uuid
+		http.Redirect(writer, req, canonicalUrl.RequestURI(), http.StatusMovedPermanently)
+		return
+	}
+
+	a = ah.visibleAuthors(writer, req, a)[0]
+	var lang string
+	if a, lang = localise(a, req.Header.Get("Accept-Language")); lang != "" {
+		writer.Header().Set("Content-Language", lang)
+	}
+
+	if prefersJSONLD(req.Header.Get("Accept")) {
+		writeResponse(toSchemaPerson(a), jsonLDMediaType, writer, req)
+		return
+	}
+	writeJSONResponse(serialize(a), writer, req)
+}
+
+func (ah *authorHandler) exportTurtle(writer http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	writeTurtle(&buf, ah.visibleAuthors(writer, req, ah.authorsService.getAllAuthors(req.Context())...))
+	writer.Header().Add("Content-Type", turtleMediaType+"; charset=utf-8")
+	buf.WriteTo(writer)
+}
+
+func (ah *authorHandler) exportNTriples(writer http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	writeNTriples(&buf, ah.visibleAuthors(writer, req, ah.authorsService.getAllAuthors(req.Context())...))
+	writer.Header().Add("Content-Type", nTriplesMediaType)
+	buf.WriteTo(writer)
+}
+
+func (ah *authorHandler) exportCSV(writer http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, ah.visibleAuthors(writer, req, ah.authorsService.getAllAuthors(req.Context())...)); err != nil {
+		log.Errorf("Error on CSV encoding=%v\n", err)
+		writeJSONError(writer, req, http.StatusInternalServerError, errorCodeInternal, err.Error(), nil)
+		return
+	}
+	writer.Header().Add("Content-Type", csvMediaType+"; charset=utf-8")
+	writer.Header().Add("Content-Disposition", `attachment; filename="authors.csv"`)
+	buf.WriteTo(writer)
+}
+
+// visibleAuthors redacts the authors according to the visibility policy. Responses that depend on the caller
+// are marked as private so that shared caches do not serve personal data to other callers.
+func (ah *authorHandler) visibleAuthors(writer http.ResponseWriter, req *http.Request, authors ...person) []person {
+	if ah.visibility != nil && len(ah.visibility.restrictedFields) > 0 {
+		writer.Header().Set("Cache-Control", "private")
+	}
+	return ah.visibility.redact(req, authors...)
+}
+
+func (ah *authorHandler) getImagesUuids(writer http.ResponseWriter, req *http.Request) {
+	uuids := ah.authorsService.getImagesUuids(req.Context())
+	writeStreamResponse(uuids, writer)
+}
+
+func (ah *authorHandler) getImageByUuid(writer http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	uuid := vars["uuid"]
 
-	a := ah.authorsService.getAuthorByUuid(uuid)
-	writeJSONResponse(a, !reflect.DeepEqual(a, person{}), writer)
+	img := ah.authorsService.getImageByUuid(req.Context(), uuid)
+	if reflect.DeepEqual(img, imageContent{}) {
+		writeJSONError(writer, req, http.StatusNotFound, errorCodeNotFound, "Image not found", map[string]string{"uuid": uuid})
+		return
+	}
+	writeJSONResponse(img, writer, req)
+}
+
+func (ah *authorHandler) getImageStatuses(writer http.ResponseWriter, req *http.Request) {
+	statuses := ah.authorsService.getImageStatuses(req.Context())
+	if req.URL.Query().Get("broken") == "true" {
+		statuses = brokenImages(statuses)
+	}
+	writeJSONResponse(statuses, writer, req)
+}
+
+// getAuditTail returns the last audited refreshes, all those kept in memory unless there is a limit.
+// The URL of Bertha is left out, as it is in the error responses.
+func (ah *authorHandler) getAuditTail(writer http.ResponseWriter, req *http.Request) {
+	limit := -1
+	if l := req.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			writeJSONError(writer, req, http.StatusBadRequest, errorCodeInvalidParameter, "Invalid limit", map[string]string{"limit": l})
+			return
+		}
+		limit = n
+	}
+	entries := ah.authorsService.getAuditTail(req.Context(), limit)
+	for i, e := range entries {
+		entries[i] = e.redacted()
+	}
+	writeJSONResponse(entries, writer, req)
 }
 
 func (ah *authorHandler) HealthCheck() v1a.Check {
 	return v1a.Check{
 		BusinessImpact:   "Unable to respond to request for curated author data from Bertha",
 		Name:             "Check connectivity to Bertha",
-		PanicGuide:       "https://sites.google.com/a/ft.com/ft-technology-service-transition/home/run-book-library/curated-authors-transformer",
+		PanicGuide:       runbookUrl,
 		Severity:         1,
 		TechnicalSummary: "Cannot connect to Bertha to be able to supply curated authors",
 		Checker:          ah.checker,
 	}
 }
 
+// checker reports the last connectivity check to Bertha made in the background, failing once Bertha has been
+// unreachable for the maximum number of consecutive checks
 func (ah *authorHandler) checker() (string, error) {
-	err := ah.authorsService.checkConnectivity()
-	if err == nil {
-		return "Connectivity to Bertha is ok", err
+	c := ah.authorsService.getConnectivity(context.Background())
+	if c.checkedAt.IsZero() {
+		return "Connectivity to Bertha has not been checked yet", nil
 	}
-	return "Error connecting to Bertha", err
+	if c.err == nil {
+		return "Connectivity to Bertha is ok", nil
+	}
+	if c.consecutiveFailures < ah.health.maxConnectivityFailures {
+		return fmt.Sprintf("Bertha has been unreachable for %d consecutive checks: %v", c.consecutiveFailures, c.err), nil
+	}
+	return "Error connecting to Bertha", c.err
 }
 
-func (ah *authorHandler) GoodToGo(writer http.ResponseWriter, req *http.Request) {
-	if _, err := ah.checker(); err != nil {
-		writer.WriteHeader(http.StatusServiceUnavailable)
+func (ah *authorHandler) ImagesHealthCheck() v1a.Check {
+	return v1a.Check{
+		BusinessImpact:   "Author pages show missing headshots",
+		Name:             "Check author images",
+		PanicGuide:       runbookUrl,
+		Severity:         3,
+		TechnicalSummary: "Some image URLs curated in Bertha do not return an image. See /transformers/authors/__images?broken=true for details",
+		Checker:          ah.imagesChecker,
 	}
 }
 
-func writeJSONResponse(obj interface{}, found bool, writer http.ResponseWriter) {
-	writer.Header().Add("Content-Type", "application/json")
+func (ah *authorHandler) imagesChecker() (string, error) {
+	broken := brokenImages(ah.authorsService.getImageStatuses(context.Background()))
+	if len(broken) == 0 {
+		return "No broken author images", nil
+	}
+	return "Some author images are broken", fmt.Errorf("%d author images are broken", len(broken))
+}
 
-	if !found {
-		writer.WriteHeader(http.StatusNotFound)
-		return
+// GoodToGo is successful when there are authors in cache to serve, unless the service is shutting down. The freshness
+// of the authors and the connectivity to Bertha are reported by the health checks only, so that a Bertha outage does
+// not take the instances serving the cached authors out of the load balancer. It never calls Bertha itself.
+func (ah *authorHandler) GoodToGo(writer http.ResponseWriter, req *http.Request) {
+	if ah.lifecycle.isShuttingDown() || ah.authorsService.getAuthorsCount(req.Context()) == 0 {
+		writer.WriteHeader(http.StatusServiceUnavailable)
 	}
+}
 
-	enc := json.NewEncoder(writer)
-	if err := enc.Encode(obj); err != nil {
+func writeJSONResponse(obj interface{}, writer http.ResponseWriter, req *http.Request) {
+	writeResponse(obj, "application/json", writer, req)
+}
+
+func writeResponse(obj interface{}, contentType string, writer http.ResponseWriter, req *http.Request) {
+	body, err := json.Marshal(obj)
+	if err != nil {
 		log.Errorf("Error on json encoding=%v\n", err)
-		writeJSONMessage(writer, err.Error(), http.StatusInternalServerError)
+		writeJSONError(writer, req, http.StatusInternalServerError, errorCodeInternal, err.Error(), nil)
 		return
 	}
+
+	writer.Header().Add("Content-Type", contentType)
+	writer.Write(append(body, '\n'))
+}
+
+// This struct is the response body of successful operations without any other content
+type message struct {
+	Message string `json:"message"`
+}
+
+func writeJSONMessage(w http.ResponseWriter, msg string, statusCode int) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(message{Message: msg})
+}
+
+const transactionIDHeader = "X-Request-Id"
+
+const (
+	errorCodeNotFound         = "NOT_FOUND"
+	errorCodeInvalidUuid      = "INVALID_UUID"
+	errorCodeInvalidParameter = "INVALID_PARAMETER"
+	errorCodeUpstreamFailure  = "UPSTREAM_FAILURE"
+	errorCodeTransformFailure = "TRANSFORM_FAILURE"
+	errorCodeUnauthorized     = "UNAUTHORIZED"
+	errorCodeThrottled        = "REFRESH_THROTTLED"
+	errorCodeInternal         = "INTERNAL_ERROR"
+)
+
+// This struct is the response body of every failed request
+type errorMessage struct {
+	Code          string            `json:"code"`
+	Message       string            `json:"message"`
+	TransactionID string            `json:"transactionId,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
 }
 
-func writeJSONMessage(w http.ResponseWriter, errorMsg string, statusCode int) {
+func writeJSONError(w http.ResponseWriter, req *http.Request, statusCode int, code string, msg string, details map[string]string) {
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	fmt.Fprintln(w, fmt.Sprintf("{\"message\": \"%s\"}", errorMsg))
+	json.NewEncoder(w).Encode(errorMessage{
+		Code:          code,
+		Message:       msg,
+		TransactionID: transactionID(req.Context()),
+		Details:       details,
+	})
+}
+
+// writeServiceError tells failures of Bertha apart from failures to transform its data
+func writeServiceError(w http.ResponseWriter, req *http.Request, err error) {
+	switch e := err.(type) {
+	case *refreshThrottledError:
+		w.Header().Set("Retry-After", strconv.Itoa(e.retryAfterSeconds()))
+		writeJSONError(w, req, http.StatusTooManyRequests, errorCodeThrottled, e.Error(), nil)
+	case *upstreamError:
+		log.WithFields(log.Fields{"source": e.url, "transaction_id": transactionID(req.Context())}).Errorf("Bertha failed: %v", e)
+		writeJSONError(w, req, http.StatusInternalServerError, errorCodeUpstreamFailure, e.message(), e.details())
+	case *transformError:
+		writeJSONError(w, req, http.StatusInternalServerError, errorCodeTransformFailure, e.Error(), e.details())
+	default:
+		writeJSONError(w, req, http.StatusInternalServerError, errorCodeInternal, err.Error(), nil)
+	}
 }
 
 func writeStreamResponse(ids []string, writer http.ResponseWriter) {
@@ -2,12 +2,16 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -20,34 +24,64 @@ type MockedBerthaService struct {
 	mock.Mock
 }
 
-func (m *MockedBerthaService) refreshCache() error {
+func (m *MockedBerthaService) refreshCache(ctx context.Context) error {
 	args := m.Called()
 	return args.Error(0)
 }
 
-func (m *MockedBerthaService) getAuthorsUuids() []string {
+func (m *MockedBerthaService) getAuthorsUuids(ctx context.Context) []string {
 	args := m.Called()
 	return args.Get(0).([]string)
 }
 
-func (m *MockedBerthaService) getAuthorByUuid(uuid string) person {
+func (m *MockedBerthaService) getAuthorByUuid(ctx context.Context, uuid string) person {
 	args := m.Called(uuid)
 	return args.Get(0).(person)
 }
 
-func (m *MockedBerthaService) getAuthorsCount() int {
+func (m *MockedBerthaService) getAuthorsCount(ctx context.Context) int {
 	args := m.Called()
 	return args.Int(0)
 }
 
-func (m *MockedBerthaService) checkConnectivity() error {
+func (m *MockedBerthaService) getAllAuthors(ctx context.Context) []person {
 	args := m.Called()
-	return args.Error(0)
+	return args.Get(0).([]person)
+}
+
+func (m *MockedBerthaService) getImagesUuids(ctx context.Context) []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *MockedBerthaService) getImageByUuid(ctx context.Context, uuid string) imageContent {
+	args := m.Called(uuid)
+	return args.Get(0).(imageContent)
+}
+
+func (m *MockedBerthaService) getImageStatuses(ctx context.Context) []imageStatus {
+	args := m.Called()
+	return args.Get(0).([]imageStatus)
+}
+
+func (m *MockedBerthaService) getRefreshStats(ctx context.Context) refreshStats {
+	args := m.Called()
+	return args.Get(0).(refreshStats)
+}
+
+func (m *MockedBerthaService) getConnectivity(ctx context.Context) connectivityStatus {
+	args := m.Called()
+	return args.Get(0).(connectivityStatus)
+}
+
+func (m *MockedBerthaService) getAuditTail(ctx context.Context, n int) []auditEntry {
+	args := m.Called(n)
+	return args.Get(0).([]auditEntry)
 }
 
 func startCuratedAuthorsTransformer(bs *MockedBerthaService) {
-	ah := newAuthorHandler(bs)
-	h := setupServiceHandlers(ah)
+	ah := newAuthorHandler(bs, nil, healthConfig{})
+	h := setupServiceHandlers(ah, true, nil)
 	curatedAuthorsTransformer = httptest.NewServer(h)
 }
 
@@ -70,21 +104,136 @@ func TestShouldReturn200AndAuthorsCount(t *testing.T) {
 	assert.Equal(t, "2", actualOutput, "Response body should contain the count of available authors")
 }
 
-func TestShouldReturn500WhenAuthorsCountIsCalledAndCacheRefreshFails(t *testing.T) {
+func TestShouldReturn500WhenCacheRefreshFails(t *testing.T) {
 	mbs := new(MockedBerthaService)
+	mbs.On("refreshCache").Return(errors.New("I hate \"Luca\"!"))
+	curatedAuthorsTransformer = httptest.NewServer(tracingHandler(setupServiceHandlers(newAuthorHandler(mbs, nil, healthConfig{}), false, nil)))
+	defer curatedAuthorsTransformer.Close()
+
+	req, _ := http.NewRequest("POST", curatedAuthorsTransformer.URL+"/transformers/authors", nil)
+	req.Header.Set("X-Request-Id", "tid_test")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "Response status should be 500")
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), "Content-Type should be application/json")
+	actualOutput := getStringFromReader(resp.Body)
+	assert.Equal(t, `{"code":"INTERNAL_ERROR","message":"I hate \"Luca\"!","transactionId":"tid_test"}`+"\n", actualOutput, "Response body should contain the error message as JSON")
+}
+
+func TestShouldReturnTransactionIDOfRequestWithoutOne(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorByUuid", martinWolfUuid).Return(person{})
+	server := httptest.NewServer(tracingHandler(setupServiceHandlers(newAuthorHandler(mbs, nil, healthConfig{}), false, nil)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/transformers/authors/" + martinWolfUuid)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	var actual errorMessage
+	json.NewDecoder(resp.Body).Decode(&actual)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "Response status should be 404")
+	assert.True(t, strings.HasPrefix(actual.TransactionID, "tid_"), "The transaction id of the request should be returned")
+}
+
+func TestShouldReturnErrorCodesOfRefreshFailures(t *testing.T) {
+	var tests = []struct {
+		err      error
+		expected errorMessage
+	}{
+		{
+			&upstreamError{url: "http://bertha/Authors", statusCode: 503, err: errors.New("Bertha returns unexpected HTTP status: 503")},
+			errorMessage{Code: "UPSTREAM_FAILURE", Message: "Bertha returns unexpected HTTP status: 503", Details: map[string]string{"status": "503"}},
+		},
+		{
+			&upstreamError{url: "http://bertha/Authors", err: errors.New(`Get "http://bertha/Authors": dial tcp: connection refused`)},
+			errorMessage{Code: "UPSTREAM_FAILURE", Message: "Bertha is unreachable"},
+		},
+		{
+			&transformError{tmeIdentifier: martinWolf.TmeIdentifier, err: errors.New("malformed biography")},
+			errorMessage{Code: "TRANSFORM_FAILURE", Message: "Cannot transform author " + martinWolf.TmeIdentifier + ": malformed biography", Details: map[string]string{"tmeIdentifier": martinWolf.TmeIdentifier}},
+		},
+	}
+
+	for _, test := range tests {
+		mbs := new(MockedBerthaService)
+		mbs.On("refreshCache").Return(test.err)
+		startCuratedAuthorsTransformer(mbs)
+
+		resp, err := http.Post(curatedAuthorsTransformer.URL+"/transformers/authors", "application/json", nil)
+		assert.Nil(t, err)
+
+		var actual errorMessage
+		json.NewDecoder(resp.Body).Decode(&actual)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "Response status should be 500")
+		assert.Equal(t, test.expected, actual, "Unexpected error message")
+		resp.Body.Close()
+		curatedAuthorsTransformer.Close()
+	}
+}
+
+func TestShouldReturn429WhenRefreshIsThrottled(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("refreshCache").Return(&refreshThrottledError{retryAfter: 1500 * time.Millisecond})
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Post(curatedAuthorsTransformer.URL+"/transformers/authors", "application/json", nil)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, "Response status should be 429")
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"), "Retry-After should be rounded up to whole seconds")
+	var actual errorMessage
+	json.NewDecoder(resp.Body).Decode(&actual)
+	assert.Equal(t, "REFRESH_THROTTLED", actual.Code, "Unexpected error code")
+}
+
+func TestShouldRefreshAuthorsBeforeCountingThemWithoutAuthentication(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("refreshCache").Return(nil)
 	mbs.On("getAuthorsCount").Return(2)
-	mbs.On("refreshCache").Return(errors.New("I hate Luca!"))
 	startCuratedAuthorsTransformer(mbs)
 	defer curatedAuthorsTransformer.Close()
 
 	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/__count")
+	assert.Nil(t, err)
 	defer resp.Body.Close()
 
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Equal(t, "2", getStringFromReader(resp.Body), "Response body should be the count")
+	mbs.AssertCalled(t, "refreshCache")
+}
+
+func TestShouldReturn500WhenAuthorsCountIsCalledAndCacheRefreshFails(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("refreshCache").Return(&upstreamError{url: "http://bertha/Authors", statusCode: 503, err: errors.New("Bertha returns unexpected HTTP status: 503")})
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/__count")
 	assert.Nil(t, err)
+	defer resp.Body.Close()
+
 	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "Response status should be 500")
-	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), "Content-Type should be application/json")
-	actualOutput := getStringFromReader(resp.Body)
-	assert.Equal(t, "{\"message\": \"I hate Luca!\"}\n", actualOutput, "Response body should contain the error message as JSON")
+	mbs.AssertNotCalled(t, "getAuthorsCount")
+}
+
+func TestShouldCountCachedAuthorsWhenRefreshIsThrottled(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("refreshCache").Return(&refreshThrottledError{retryAfter: time.Second})
+	mbs.On("getAuthorsCount").Return(2)
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/__count")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Equal(t, "2", getStringFromReader(resp.Body), "Response body should be the cached count")
 }
 
 func TestShouldReturn200AndAuthorsUuids(t *testing.T) {
@@ -132,6 +281,92 @@ func TestShouldReturn200AndTrasformedAuthor(t *testing.T) {
 	assert.Equal(t, expectedOutput, actualOutput, "Response body should be Martin Wolf")
 }
 
+func TestShouldReturnLocalisedAuthorWhenLanguageIsAccepted(t *testing.T) {
+	localisedMartinWolf := transformedMartinWolf
+	localisedMartinWolf.LocalisedDescriptions = map[string]localisedDescription{
+		"zh": {Description: "马丁·沃尔夫", DescriptionXML: "<p>马丁·沃尔夫</p>"},
+	}
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorByUuid", martinWolfUuid).Return(localisedMartinWolf)
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	req, _ := http.NewRequest("GET", curatedAuthorsTransformer.URL+"/transformers/authors/"+martinWolfUuid, nil)
+	req.Header.Set("Accept-Language", "zh-CN,en;q=0.5")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	var p person
+	json.NewDecoder(resp.Body).Decode(&p)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Equal(t, "zh", resp.Header.Get("Content-Language"), "Content-Language should be Chinese")
+	assert.Contains(t, resp.Header["Vary"], "Accept-Language", "The response should vary by language")
+	assert.Equal(t, "马丁·沃尔夫", p.Description, "The description should be in Chinese")
+	assert.Equal(t, "<p>马丁·沃尔夫</p>", p.DescriptionXML, "The description XML should be in Chinese")
+}
+
+func TestShouldReturnJSONLDWhenRequested(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorByUuid", martinWolfUuid).Return(transformedMartinWolf)
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	req, _ := http.NewRequest("GET", curatedAuthorsTransformer.URL+"/transformers/authors/"+martinWolfUuid, nil)
+	req.Header.Set("Accept", "application/ld+json, application/json;q=0.9")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Equal(t, "application/ld+json", resp.Header.Get("Content-Type"), "Content-Type should be application/ld+json")
+	assert.Contains(t, resp.Header["Vary"], "Accept", "The response should vary by media type")
+
+	file, _ := os.Open("test-resources/martin-wolf-json-ld-output.json")
+	defer file.Close()
+	assert.Equal(t, getStringFromReader(file), getStringFromReader(resp.Body), "Response body should be Martin Wolf as schema.org Person")
+}
+
+func TestShouldReturnVersionedAuthor(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorByUuid", martinWolfUuid).Return(transformedMartinWolf)
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	var tests = []struct {
+		path     string
+		expected string
+	}{
+		{"/v1/transformers/authors/", "test-resources/martin-wolf-transformed-output.json"},
+		{"/v2/transformers/authors/", "test-resources/martin-wolf-transformed-output-v2.json"},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(curatedAuthorsTransformer.URL + test.path + martinWolfUuid)
+		assert.Nil(t, err)
+
+		file, _ := os.Open(test.expected)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+		assert.Equal(t, getStringFromReader(file), getStringFromReader(resp.Body), "Unexpected response body for "+test.path)
+		file.Close()
+		resp.Body.Close()
+	}
+}
+
+func TestShouldReturnVersionedAuthorsUuids(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorsUuids").Return(expectedUuids)
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/v2/transformers/authors/__ids")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Equal(t, expectedStreamOutput, getStringFromReader(resp.Body), "Response body should be a sequence of ids")
+}
+
 func TestShouldReturn404WhenAuthorIsNotFound(t *testing.T) {
 	mbs := new(MockedBerthaService)
 	mbs.On("getAuthorByUuid", martinWolfUuid).Return(person{})
@@ -143,4 +378,163 @@ func TestShouldReturn404WhenAuthorIsNotFound(t *testing.T) {
 
 	assert.Nil(t, err)
 	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "Response status should be 404")
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), "Content-Type should be application/json")
+	expectedOutput := `{"code":"NOT_FOUND","message":"Author not found","details":{"uuid":"` + martinWolfUuid + `"}}` + "\n"
+	assert.Equal(t, expectedOutput, getStringFromReader(resp.Body), "Response body should explain the author is not found")
+}
+
+func TestShouldReturnAuthorWithUppercasedUuid(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorByUuid", martinWolfUuid).Return(transformedMartinWolf)
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/" + strings.ToUpper(martinWolfUuid))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	mbs.AssertExpectations(t)
+}
+
+func TestShouldReturn400WhenAuthorUuidIsInvalid(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/martin-wolf")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "Response status should be 400")
+	expectedOutput := `{"code":"INVALID_UUID","message":"Invalid author UUID","details":{"uuid":"martin-wolf"}}` + "\n"
+	assert.Equal(t, expectedOutput, getStringFromReader(resp.Body), "Response body should explain the UUID is invalid")
+	mbs.AssertNotCalled(t, "getAuthorByUuid", mock.Anything)
+}
+
+func TestShouldRedirectFromAlternativeUuid(t *testing.T) {
+	altUuid := "4e3ad5fd-5c5c-4dd4-a1a4-3d3f1bb3a9b2"
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorByUuid", altUuid).Return(transformedMartinWolf)
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	noRedirectClient := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirectClient.Get(curatedAuthorsTransformer.URL + "/v2/transformers/authors/" + strings.ToUpper(altUuid) + "?q=1")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode, "Response status should be 301")
+	assert.Equal(t, "/v2/transformers/authors/"+martinWolfUuid+"?q=1", resp.Header.Get("Location"), "Location should be the canonical author")
+}
+
+func TestShouldReturn200AndImageSet(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getImageByUuid", martinWolfImageSetUuid).Return(imageContents(transformedMartinWolf)[0])
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/author-images/" + martinWolfImageSetUuid)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), "Content-Type should be application/json")
+	expectedOutput := `{"uuid":"` + martinWolfImageSetUuid + `","type":"ImageSet","title":"Author headshot","members":[{"uuid":"` + martinWolfImageUuid + `","binaryUrl":"` + martinWolf.ImageUrl + `"}]}` + "\n"
+	assert.Equal(t, expectedOutput, getStringFromReader(resp.Body), "Response body should be Martin Wolf's image set")
+}
+
+func TestShouldReturn404WhenImagesAreNotPublished(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	h := setupServiceHandlers(newAuthorHandler(mbs, nil, healthConfig{}), false, nil)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/transformers/author-images/" + martinWolfImageSetUuid)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "Response status should be 404")
+	mbs.AssertNotCalled(t, "getImageByUuid", martinWolfImageSetUuid)
+}
+
+func TestShouldReturn200AndBrokenImages(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getImageStatuses").Return([]imageStatus{
+		{AuthorUuid: martinWolfUuid, ImageUrl: martinWolf.ImageUrl, StatusCode: 200, ContentType: "image/png"},
+		{AuthorUuid: lucyKellawayUuid, ImageUrl: lucyKellaway.ImageUrl, StatusCode: 404, Broken: true},
+	})
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/__images?broken=true")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	expectedOutput := `[{"authorUuid":"` + lucyKellawayUuid + `","imageUrl":"` + lucyKellaway.ImageUrl + `","statusCode":404,"broken":true}]` + "\n"
+	assert.Equal(t, expectedOutput, getStringFromReader(resp.Body), "Response body should contain only the broken images")
+}
+
+func TestImagesHealthCheckShouldFailWhenImagesAreBroken(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getImageStatuses").Return([]imageStatus{{AuthorUuid: lucyKellawayUuid, Broken: true}})
+	ah := newAuthorHandler(mbs, nil, healthConfig{})
+
+	_, err := ah.ImagesHealthCheck().Checker()
+	assert.NotNil(t, err, "The check should fail")
+}
+
+func TestShouldReturn200AndAuthorsAsTurtle(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getAllAuthors").Return([]person{transformedMartinWolf})
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/__export.ttl")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Equal(t, "text/turtle; charset=utf-8", resp.Header.Get("Content-Type"), "Content-Type should be text/turtle")
+	file, _ := os.Open("test-resources/authors-export.ttl")
+	defer file.Close()
+	assert.Equal(t, getStringFromReader(file), getStringFromReader(resp.Body), "Response body should be Martin Wolf in Turtle")
+}
+
+func TestShouldReturn200AndAuthorsAsNTriples(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getAllAuthors").Return([]person{transformedMartinWolf})
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/__export.nt")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Equal(t, "application/n-triples", resp.Header.Get("Content-Type"), "Content-Type should be application/n-triples")
+	file, _ := os.Open("test-resources/authors-export.nt")
+	defer file.Close()
+	assert.Equal(t, getStringFromReader(file), getStringFromReader(resp.Body), "Response body should be Martin Wolf in N-Triples")
+}
+
+func TestShouldReturn200AndAuthorsAsCSV(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getAllAuthors").Return([]person{transformedMartinWolf, aPerson})
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/transformers/authors/__export.csv")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"), "Content-Type should be text/csv")
+	assert.Equal(t, `attachment; filename="authors.csv"`, resp.Header.Get("Content-Disposition"))
+	file, _ := os.Open("test-resources/authors-export.csv")
+	defer file.Close()
+	assert.Equal(t, getStringFromReader(file), getStringFromReader(resp.Body), "Response body should be Martin Wolf and Eric Cartman as CSV")
 }
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldCollectLocalisedBiographies(t *testing.T) {
+	row := `{"name": "Lucy Kellaway", "biography": "Lucy", "biography_zh": "露西", "biography_PT-BR": "Lúcia", "biography_zh_Hans": "露西", "biography_ja": "", "biography_fr": null, "biographyformat": "html"}`
+
+	var a author
+	err := json.Unmarshal([]byte(row), &a)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Lucy", a.Biography)
+	assert.Equal(t, "html", a.BiographyFormat, "The biography format should not be a localised biography")
+	assert.Equal(t, map[string]string{"zh": "露西", "pt-br": "Lúcia", "zh-hans": "露西"}, a.LocalisedBiographies, "Only non empty localised biographies should be collected")
+}
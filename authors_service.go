@@ -1,9 +1,17 @@
 package main
 
+import "context"
+
 type authorsService interface {
-	refreshCache() error
-	getAuthorsCount() int
-	getAuthorsUuids() []string
-	getAuthorByUuid(uuid string) person
-	checkConnectivity() error
+	refreshCache(ctx context.Context) error
+	getAuthorsCount(ctx context.Context) int
+	getAuthorsUuids(ctx context.Context) []string
+	getAuthorByUuid(ctx context.Context, uuid string) person
+	getAllAuthors(ctx context.Context) []person
+	getImagesUuids(ctx context.Context) []string
+	getImageByUuid(ctx context.Context, uuid string) imageContent
+	getImageStatuses(ctx context.Context) []imageStatus
+	getRefreshStats(ctx context.Context) refreshStats
+	getConnectivity(ctx context.Context) connectivityStatus
+	getAuditTail(ctx context.Context, n int) []auditEntry
 }
@@ -1,86 +1,410 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gregjones/httpcache"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
-var client = httpcache.NewMemoryCacheTransport().Client()
+var client = newBerthaClient()
+
+// newBerthaClient caches the responses of Bertha, revalidating them with their ETag, and counts the actual responses of Bertha
+func newBerthaClient() *http.Client {
+	t := httpcache.NewMemoryCacheTransport()
+	t.Transport = &upstreamCounter{next: http.DefaultTransport}
+	return t.Client()
+}
 
 type berthaService struct {
-	berthaUrl   string
-	authorsMap  map[string]person
-	transformer transformer
-	mutex       *sync.Mutex
+	berthaUrl     string
+	authorsMap    map[string]person
+	canonicalIds  map[string]string
+	imagesMap     map[string]imageContent
+	transformer   transformer
+	imageChecker  *imageChecker
+	imageStatuses []imageStatus
+	stats         refreshStats
+	connectivity  connectivityStatus
+	mutex         *sync.Mutex
+
+	timeout            time.Duration
+	minRefreshInterval time.Duration
+	refreshMutex       *sync.Mutex
+	inFlightRefresh    *refreshCall
+	lastRefresh        time.Time
+
+	checkingImages  bool
+	imagesToRecheck bool
+
+	audit *auditLog
 }
 
-func newBerthaService(url string) (*berthaService, error) {
-	bs := &berthaService{
-		berthaUrl:   url,
-		authorsMap:  map[string]person{},
-		transformer: &berthaTransformer{},
-		mutex:       &sync.Mutex{},
-	}
-	err := bs.refreshCache()
+// refreshStats describes the last refreshes of the cache for the health checks. lastSuccess is the last time the cache
+// was replaced, even when some authors could not be transformed, and previousAuthorsCount is the number of authors
+// it replaced. The fetches from Bertha that fail change neither.
+type refreshStats struct {
+	lastSuccess          time.Time
+	previousAuthorsCount int
+	fetchedAuthors       int
+	transformErrors      int
+	berthaLatency        time.Duration
+}
+
+// connectivityStatus is the outcome of the last connectivity check to Bertha
+type connectivityStatus struct {
+	err                 error
+	consecutiveFailures int
+	checkedAt           time.Time
+}
+
+// refreshCall is a fetch from Bertha shared by all the refresh requests received while it is in flight
+type refreshCall struct {
+	done    chan struct{}
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// newBerthaService creates the service and loads the authors. The images of the authors are checked
+// on every refresh unless the image checker is nil. Bertha is fetched at most once per minRefreshInterval,
+// and its calls are cancelled after the timeout unless it is zero.
+func newBerthaService(url string, t transformer, ic *imageChecker, minRefreshInterval time.Duration, timeout time.Duration) (*berthaService, error) {
+	bs := newEmptyBerthaService(url, t, ic, minRefreshInterval, timeout)
+	err := bs.refreshCache(withTransactionID(context.Background(), newTransactionID()))
 	return bs, err
 }
 
-func (bs *berthaService) refreshCache() error {
+// newEmptyBerthaService creates the service without loading the authors, which are fetched on the first refresh
+func newEmptyBerthaService(url string, t transformer, ic *imageChecker, minRefreshInterval time.Duration, timeout time.Duration) *berthaService {
+	return &berthaService{
+		berthaUrl:          url,
+		authorsMap:         map[string]person{},
+		canonicalIds:       map[string]string{},
+		imagesMap:          map[string]imageContent{},
+		transformer:        t,
+		imageChecker:       ic,
+		mutex:              &sync.Mutex{},
+		timeout:            timeout,
+		minRefreshInterval: minRefreshInterval,
+		refreshMutex:       &sync.Mutex{},
+	}
+}
+
+// refreshCache fetches the authors from Bertha. Concurrent calls share the fetch in flight, and calls within
+// the minimum interval since the last successful fetch return a refreshThrottledError without calling Bertha.
+// A caller stops waiting when its context is done, and the fetch is cancelled when no caller waits for it anymore.
+func (bs *berthaService) refreshCache(ctx context.Context) (err error) {
+	ctx, s := startSpan(ctx, "refreshCache")
+	defer func() { s.finish(err) }()
+
+	bs.refreshMutex.Lock()
+	c := bs.inFlightRefresh
+	if c != nil {
+		s.setAttribute("refresh.coalesced", true)
+	} else {
+		if wait := bs.lastRefresh.Add(bs.minRefreshInterval).Sub(time.Now()); !bs.lastRefresh.IsZero() && wait > 0 {
+			bs.refreshMutex.Unlock()
+			s.setAttribute("refresh.throttled", true)
+			return &refreshThrottledError{retryAfter: wait}
+		}
+		c = bs.startRefresh(ctx)
+	}
+	c.waiters++
+	bs.refreshMutex.Unlock()
+
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		bs.refreshMutex.Lock()
+		if c.waiters--; c.waiters == 0 {
+			c.cancel()
+		}
+		bs.refreshMutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+// startRefresh starts a fetch from Bertha shared by the callers, the caller holding the refresh lock.
+// The fetch keeps the values of the context of the first caller, like its trace, but not its cancellation.
+func (bs *berthaService) startRefresh(ctx context.Context) *refreshCall {
+	fetchCtx, cancel := context.WithCancel(detachedContext{ctx})
+	c := &refreshCall{done: make(chan struct{}), cancel: cancel}
+	bs.inFlightRefresh = c
+
+	go func() {
+		defer cancel()
+		start := time.Now()
+		replaced, err := bs.fetchAndTransform(fetchCtx)
+		c.err = err
+		refreshDuration.UpdateSince(start)
+		if replaced {
+			refreshSuccesses.Inc(1)
+			lastRefreshUnixTime.Update(time.Now().Unix())
+		} else {
+			refreshFailures.Inc(1)
+		}
+
+		bs.refreshMutex.Lock()
+		bs.inFlightRefresh = nil
+		if replaced {
+			bs.lastRefresh = time.Now()
+		}
+		bs.refreshMutex.Unlock()
+		close(c.done)
+	}()
+	return c
+}
+
+// waitForRefresh waits for the fetch in flight, if any, and cancels it when the context is done first
+func (bs *berthaService) waitForRefresh(ctx context.Context) error {
+	bs.refreshMutex.Lock()
+	c := bs.inFlightRefresh
+	bs.refreshMutex.Unlock()
+	if c == nil {
+		return nil
+	}
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		c.cancel()
+		<-c.done
+		return ctx.Err()
+	}
+}
+
+// detachedContext keeps the values of a context without its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (d detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (d detachedContext) Done() <-chan struct{}             { return nil }
+func (d detachedContext) Err() error                        { return nil }
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// fetchAndTransform refreshes the authors, then checks their images when the cached authors were replaced
+func (bs *berthaService) fetchAndTransform(ctx context.Context) (bool, error) {
+	replaced, err := bs.refreshAuthors(ctx)
+	if replaced && bs.imageChecker != nil {
+		bs.checkImagesInBackground()
+	}
+	return replaced, err
+}
+
+// checkImagesInBackground verifies the images of the cached authors without delaying the refresh.
+// A refresh during a check makes it run again once over, so that the statuses match the cached authors.
+func (bs *berthaService) checkImagesInBackground() {
 	bs.mutex.Lock()
 	defer bs.mutex.Unlock()
+	if bs.checkingImages {
+		bs.imagesToRecheck = true
+		return
+	}
+	bs.checkingImages = true
+	go func() {
+		for {
+			bs.refreshImageStatuses()
+			bs.mutex.Lock()
+			if !bs.imagesToRecheck {
+				bs.checkingImages = false
+				bs.mutex.Unlock()
+				return
+			}
+			bs.imagesToRecheck = false
+			bs.mutex.Unlock()
+		}
+	}()
+}
 
-	bs.authorsMap = make(map[string]person)
+// refreshAuthors fetches and transforms the authors before replacing the cached ones, so that the lock is not held
+// while Bertha is called. The cached authors are kept when Bertha cannot be fetched, and replaced by the authors
+// that can be transformed otherwise, in which case replaced is true even with a transformError. Every refresh is audited.
+func (bs *berthaService) refreshAuthors(ctx context.Context) (replaced bool, err error) {
+	ctx, s := startSpan(ctx, "refreshAuthors")
+	defer func() { s.finish(err) }()
 
-	authors, err := bs.getAuthors()
+	start := time.Now()
+	entry := newAuditEntry(ctx, bs.berthaUrl)
+	defer func() {
+		entry.DurationMillis = int64(time.Since(start) / time.Millisecond)
+		if err != nil {
+			entry.Error = err.Error()
+			entry.publicError = publicErrorMessage(err)
+		}
+		bs.audit.record(entry)
+	}()
 
+	authors, err := bs.getAuthors(ctx, &entry)
+	latency := time.Since(start)
 	if err != nil {
-		return err
+		bs.mutex.Lock()
+		bs.stats.berthaLatency = latency
+		entry.AuthorsBefore = len(bs.authorsMap)
+		entry.AuthorsAfter = len(bs.authorsMap)
+		bs.mutex.Unlock()
+		return false, err
 	}
 
+	authorsMap := make(map[string]person)
+	canonicalIds := make(map[string]string)
+	imagesMap := make(map[string]imageContent)
+
+	var firstErr error
+	transformErrorsCount := 0
 	for _, a := range authors {
-		p, transErr := bs.transformer.authorToPerson(a)
+		p, transErr := bs.transformer.authorToPerson(ctx, a)
 		if transErr != nil {
-			log.Error(err)
-			return transErr
+			log.Error(transErr)
+			transformErrors.Inc(1)
+			transformErrorsCount++
+			if firstErr == nil {
+				firstErr = &transformError{tmeIdentifier: a.TmeIdentifier, err: transErr}
+			}
+			continue
+		}
+		authorsMap[p.Uuid] = p
+		for _, altUuid := range p.AlternativeIdentifiers.UUIDS {
+			if id := strings.ToLower(altUuid); id != p.Uuid {
+				canonicalIds[id] = p.Uuid
+			}
+		}
+		for _, img := range imageContents(p) {
+			imagesMap[img.UUID] = img
 		}
-		bs.authorsMap[p.Uuid] = p
 	}
-	return nil
+	s.setAttribute("authors.fetched", len(authors))
+	s.setAttribute("authors.cached", len(authorsMap))
+	s.setAttribute("authors.transform_errors", transformErrorsCount)
+
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	entry.AuthorsBefore = len(bs.authorsMap)
+	entry.AuthorsAfter = len(authorsMap)
+	entry.Diff = diffAuthors(bs.authorsMap, authorsMap)
+	bs.stats.previousAuthorsCount = len(bs.authorsMap)
+	bs.stats.lastSuccess = time.Now()
+	bs.stats.berthaLatency = latency
+	bs.stats.fetchedAuthors = len(authors)
+	bs.stats.transformErrors = transformErrorsCount
+	bs.authorsMap = authorsMap
+	bs.canonicalIds = canonicalIds
+	bs.imagesMap = imagesMap
+	authorsInCache.Update(int64(len(authorsMap)))
+	return true, firstErr
+}
+
+// refreshImageStatuses checks the images without holding the lock, so that authors can be served in the meantime
+func (bs *berthaService) refreshImageStatuses() {
+	bs.mutex.Lock()
+	authors := make([]person, 0, len(bs.authorsMap))
+	for _, p := range bs.authorsMap {
+		authors = append(authors, p)
+	}
+	bs.mutex.Unlock()
+
+	statuses := bs.imageChecker.check(authors)
+	broken := brokenImages(statuses)
+	if len(broken) > 0 {
+		log.WithFields(log.Fields{"broken_images": len(broken), "checked_images": len(statuses)}).Warn("Some author images are broken")
+	}
+
+	bs.mutex.Lock()
+	bs.imageStatuses = statuses
+	bs.mutex.Unlock()
 }
 
-func (bs *berthaService) getAuthors() ([]author, error) {
-	resp, err := bs.callBerthaService()
+// getAuthors fetches the authors from Bertha, recording its response in the audit entry
+func (bs *berthaService) getAuthors(ctx context.Context, entry *auditEntry) ([]author, error) {
+	resp, err := bs.callBerthaService(ctx)
 	if err != nil {
 		log.Error(err)
-		return []author{}, err
+		return []author{}, &upstreamError{url: bs.berthaUrl, err: err}
+	}
+	defer resp.Body.Close()
+	entry.UpstreamStatus = resp.StatusCode
+	entry.ETag = resp.Header.Get("ETag")
+	entry.FromCache = resp.Header.Get("X-From-Cache") == "1"
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("Bertha returns unexpected HTTP status: %d", resp.StatusCode)
+		log.Error(err)
+		return []author{}, &upstreamError{url: bs.berthaUrl, statusCode: resp.StatusCode, err: err}
 	}
 
 	var authors []author
 	if err = json.NewDecoder(resp.Body).Decode(&authors); err != nil {
 		log.Error(err)
-		return []author{}, err
+		return []author{}, &upstreamError{url: bs.berthaUrl, statusCode: resp.StatusCode, err: err}
 	}
 	return authors, nil
 }
 
-func (bs *berthaService) callBerthaService() (res *http.Response, err error) {
-	log.WithFields(log.Fields{"bertha_url": bs.berthaUrl}).Info("Calling Bertha...")
-	res, err = client.Get(bs.berthaUrl)
-	return
+// callBerthaService gets the authors from Bertha with the transaction id and the trace of the context.
+// The call is cancelled with the context or after the timeout, including the reading of the response body.
+func (bs *berthaService) callBerthaService(ctx context.Context) (res *http.Response, err error) {
+	ctx, s := startSpan(ctx, "GET Bertha")
+	s.setAttribute("http.url", bs.berthaUrl)
+	defer func() { s.finish(err) }()
+
+	cancel := context.CancelFunc(func() {})
+	if bs.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, bs.timeout)
+	}
+
+	log.WithFields(log.Fields{"bertha_url": bs.berthaUrl, "transaction_id": transactionID(ctx)}).Info("Calling Bertha...")
+	req, err := http.NewRequest("GET", bs.berthaUrl, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	injectTracing(ctx, req)
+
+	start := time.Now()
+	res, err = client.Do(req)
+	berthaDuration.UpdateSince(start)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	countBerthaResponse(res)
+	s.setAttribute("http.status_code", res.StatusCode)
+	s.setAttribute("http.from_cache", res.Header.Get("X-From-Cache") == "1")
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
 }
 
-func (bs *berthaService) getAuthorsCount() int {
+// cancelOnClose releases the context of a response when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+func (bs *berthaService) getAuthorsCount(ctx context.Context) int {
 	bs.mutex.Lock()
 	defer bs.mutex.Unlock()
 	return len(bs.authorsMap)
 }
 
-func (bs *berthaService) getAuthorsUuids() []string {
+func (bs *berthaService) getAuthorsUuids(ctx context.Context) []string {
 	bs.mutex.Lock()
 	defer bs.mutex.Unlock()
 	uuids := make([]string, 0)
@@ -90,19 +414,177 @@ func (bs *berthaService) getAuthorsUuids() []string {
 	return uuids
 }
 
-func (bs *berthaService) getAuthorByUuid(uuid string) person {
+// getAuthorByUuid finds an author by its canonical UUID or by any of its alternative UUIDs
+func (bs *berthaService) getAuthorByUuid(ctx context.Context, uuid string) person {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	if p, found := bs.authorsMap[uuid]; found {
+		return p
+	}
+	return bs.authorsMap[bs.canonicalIds[uuid]]
+}
+
+// getAllAuthors returns the cached authors ordered by UUID
+func (bs *berthaService) getAllAuthors(ctx context.Context) []person {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	authors := make([]person, 0, len(bs.authorsMap))
+	for _, p := range bs.authorsMap {
+		authors = append(authors, p)
+	}
+	sort.Sort(byUuid(authors))
+	return authors
+}
+
+func (bs *berthaService) getImagesUuids(ctx context.Context) []string {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	uuids := make([]string, 0)
+	for uuid := range bs.imagesMap {
+		uuids = append(uuids, uuid)
+	}
+	return uuids
+}
+
+func (bs *berthaService) getImageByUuid(ctx context.Context, uuid string) imageContent {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	return bs.imagesMap[uuid]
+}
+
+func (bs *berthaService) getImageStatuses(ctx context.Context) []imageStatus {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	return append([]imageStatus{}, bs.imageStatuses...)
+}
+
+// getRefreshStats returns the outcome of the last refreshes and connectivity checks
+func (bs *berthaService) getRefreshStats(ctx context.Context) refreshStats {
 	bs.mutex.Lock()
 	defer bs.mutex.Unlock()
-	return bs.authorsMap[uuid]
+	return bs.stats
 }
 
+// getAuditTail returns the last n audited refreshes, oldest first
+func (bs *berthaService) getAuditTail(ctx context.Context, n int) []auditEntry {
+	return bs.audit.tail(n)
+}
+
+// checkConnectivity calls Bertha and records the outcome as the connectivity status
 func (bs *berthaService) checkConnectivity() error {
-	resp, err := bs.callBerthaService()
+	start := time.Now()
+	err := bs.callBerthaForConnectivity(withTransactionID(context.Background(), newTransactionID()))
+	latency := time.Since(start)
+
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	bs.stats.berthaLatency = latency
+	bs.connectivity.err = err
+	bs.connectivity.checkedAt = time.Now()
+	if err != nil {
+		bs.connectivity.consecutiveFailures++
+	} else {
+		bs.connectivity.consecutiveFailures = 0
+	}
+	return err
+}
+
+func (bs *berthaService) callBerthaForConnectivity(ctx context.Context) error {
+	resp, err := bs.callBerthaService(ctx)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
 	if resp.StatusCode != http.StatusOK {
 		return errors.New(fmt.Sprintf("Bertha returns unexpected HTTP status: %d", resp.StatusCode))
 	}
 	return nil
 }
+
+// monitorConnectivity checks the connectivity to Bertha every interval until done is closed,
+// so that health checks and GTG probes do not call Bertha themselves
+func (bs *berthaService) monitorConnectivity(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := bs.checkConnectivity(); err != nil {
+			log.Warnf("Bertha is not reachable: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// getConnectivity returns the outcome of the last connectivity check to Bertha
+func (bs *berthaService) getConnectivity(ctx context.Context) connectivityStatus {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	return bs.connectivity
+}
+
+type byUuid []person
+
+func (p byUuid) Len() int           { return len(p) }
+func (p byUuid) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byUuid) Less(i, j int) bool { return p[i].Uuid < p[j].Uuid }
+
+// upstreamError is returned when Bertha cannot be reached or returns unusable data
+type upstreamError struct {
+	url        string
+	statusCode int
+	err        error
+}
+
+func (e *upstreamError) Error() string {
+	return e.err.Error()
+}
+
+// message describes the failure to the clients, without the URL of the Bertha spreadsheet or of its proxies
+func (e *upstreamError) message() string {
+	switch e.statusCode {
+	case 0:
+		return "Bertha is unreachable"
+	case http.StatusOK:
+		return "Bertha returns malformed authors"
+	}
+	return fmt.Sprintf("Bertha returns unexpected HTTP status: %d", e.statusCode)
+}
+
+func (e *upstreamError) details() map[string]string {
+	if e.statusCode == 0 {
+		return nil
+	}
+	return map[string]string{"status": strconv.Itoa(e.statusCode)}
+}
+
+// refreshThrottledError is returned when a refresh is requested too soon after the last fetch from Bertha
+type refreshThrottledError struct {
+	retryAfter time.Duration
+}
+
+func (e *refreshThrottledError) Error() string {
+	return fmt.Sprintf("Authors have just been refreshed, retry in %d seconds", e.retryAfterSeconds())
+}
+
+// retryAfterSeconds rounds the time to wait before the next refresh up to whole seconds
+func (e *refreshThrottledError) retryAfterSeconds() int {
+	return int((e.retryAfter + time.Second - 1) / time.Second)
+}
+
+// transformError is returned when an author from Bertha cannot be transformed into a person
+type transformError struct {
+	tmeIdentifier string
+	err           error
+}
+
+func (e *transformError) Error() string {
+	return fmt.Sprintf("Cannot transform author %s: %v", e.tmeIdentifier, e.err)
+}
+
+func (e *transformError) details() map[string]string {
+	return map[string]string{"tmeIdentifier": e.tmeIdentifier}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
@@ -9,7 +11,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 const etag = "W/\"75e-78600296\""
@@ -53,7 +59,7 @@ type MockedTransformer struct {
 	mock.Mock
 }
 
-func (m *MockedTransformer) authorToPerson(a author) (person, error) {
+func (m *MockedTransformer) authorToPerson(ctx context.Context, a author) (person, error) {
 	args := m.Called(a)
 	return args.Get(0).(person), args.Error(1)
 }
@@ -62,9 +68,9 @@ func TestShouldReturnAuthorsCount(t *testing.T) {
 	startBerthaMock("happy")
 	defer berthaMock.Close()
 	spreadSheetUrl := berthaMock.URL + berthaPath
-	bs, err := newBerthaService(spreadSheetUrl)
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
 
-	c := bs.getAuthorsCount()
+	c := bs.getAuthorsCount(context.Background())
 
 	assert.Nil(t, err)
 	assert.Equal(t, 2, c, "Bertha should return 2 authors")
@@ -74,9 +80,9 @@ func TestShouldReturnAuthorsUuids(t *testing.T) {
 	startBerthaMock("happy")
 	defer berthaMock.Close()
 	spreadSheetUrl := berthaMock.URL + berthaPath
-	bs, err := newBerthaService(spreadSheetUrl)
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
 
-	uuids := bs.getAuthorsUuids()
+	uuids := bs.getAuthorsUuids(context.Background())
 
 	assert.Nil(t, err)
 	assert.Equal(t, 2, len(uuids), "Bertha should return 2 authors")
@@ -88,24 +94,281 @@ func TestShouldReturnSingleAuthor(t *testing.T) {
 	startBerthaMock("happy")
 	defer berthaMock.Close()
 	spreadSheetUrl := berthaMock.URL + berthaPath
-	bs, err := newBerthaService(spreadSheetUrl)
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
 
-	a := bs.getAuthorByUuid(martinWolfUuid)
+	a := bs.getAuthorByUuid(context.Background(), martinWolfUuid)
 
 	assert.Nil(t, err)
 	assert.Equal(t, transformedMartinWolf, a, "The author should be Martin Wolf")
 }
 
+func TestShouldReturnImagesOfAuthors(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	spreadSheetUrl := berthaMock.URL + berthaPath
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(bs.getImagesUuids(context.Background())), "There should be an image set and an image per author")
+	img := bs.getImageByUuid(context.Background(), martinWolfImageUuid)
+	assert.Equal(t, imageContent{UUID: martinWolfImageUuid, Type: "Image", Title: imageTitle, BinaryUrl: martinWolf.ImageUrl}, img, "The image should be Martin Wolf's headshot")
+}
+
+func TestShouldCheckImagesOnRefresh(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	images := startImagesMock()
+	defer images.Close()
+	mt := new(MockedTransformer)
+	mt.On("authorToPerson", mock.Anything).Return(person{Uuid: martinWolfUuid, ImageUrl: images.URL + "/martin-wolf.png"}, nil)
+	spreadSheetUrl := berthaMock.URL + berthaPath
+	bs, err := newBerthaService(spreadSheetUrl, mt, newImageChecker(2, time.Second), 0, 0)
+
+	assert.Nil(t, err)
+	statuses := waitForImageStatuses(bs)
+	assert.Equal(t, 1, len(statuses), "The image of each author should be checked")
+	assert.False(t, statuses[0].Broken, "The image should not be broken")
+}
+
+func TestShouldNotWaitForImageCheckToRefresh(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	release := make(chan struct{})
+	slowImages := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "image/png")
+	}))
+	defer slowImages.Close()
+	mt := new(MockedTransformer)
+	mt.On("authorToPerson", mock.Anything).Return(person{Uuid: martinWolfUuid, ImageUrl: slowImages.URL + "/martin-wolf.png"}, nil)
+
+	start := time.Now()
+	bs, err := newBerthaService(berthaMock.URL+berthaPath, mt, newImageChecker(2, 5*time.Second), 0, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, bs.refreshCache(context.Background()))
+	assert.True(t, time.Since(start) < time.Second, "The refreshes should not wait for the image hosts")
+	assert.Empty(t, bs.getImageStatuses(context.Background()), "The images should still be checked")
+
+	close(release)
+	assert.Len(t, waitForImageStatuses(bs), 1, "The images should be checked in the background")
+}
+
+func waitForImageStatuses(bs *berthaService) []imageStatus {
+	for i := 0; i < 100; i++ {
+		if statuses := bs.getImageStatuses(context.Background()); len(statuses) > 0 {
+			return statuses
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func TestShouldFindAuthorByAlternativeUuid(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	altUuid := "4e3ad5fd-5c5c-4dd4-a1a4-3d3f1bb3a9b2"
+	mt := new(MockedTransformer)
+	mt.On("authorToPerson", mock.Anything).Return(person{
+		Uuid:                   martinWolfUuid,
+		AlternativeIdentifiers: alternativeIdentifiers{UUIDS: []string{martinWolfUuid, strings.ToUpper(altUuid)}},
+	}, nil)
+	spreadSheetUrl := berthaMock.URL + berthaPath
+	bs, err := newBerthaService(spreadSheetUrl, mt, nil, 0, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, martinWolfUuid, bs.getAuthorByUuid(context.Background(), altUuid).Uuid, "The author should be found by its alternative UUID")
+	assert.Equal(t, 1, bs.getAuthorsCount(context.Background()), "Alternative UUIDs should not be counted as authors")
+}
+
+func TestShouldShareRefreshInFlight(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	slowBertha := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		berthaHandlerMock(w, r)
+	}))
+	defer slowBertha.Close()
+	bs, err := newBerthaService(slowBertha.URL, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	refresh := func() {
+		defer wg.Done()
+		assert.Nil(t, bs.refreshCache(context.Background()))
+	}
+	wg.Add(1)
+	go refresh()
+	for atomic.LoadInt32(&fetches) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go refresh()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "Concurrent refreshes should share one fetch from Bertha")
+	assert.Equal(t, 2, bs.getAuthorsCount(context.Background()), "The authors should be refreshed")
+}
+
+func TestShouldThrottleRefreshesWithinMinimumInterval(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	spreadSheetUrl := berthaMock.URL + berthaPath
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, time.Minute, 0)
+	assert.Nil(t, err)
+
+	err = bs.refreshCache(context.Background())
+	throttled, ok := err.(*refreshThrottledError)
+	assert.True(t, ok, "A refresh right after the last one should be throttled")
+	assert.Equal(t, 60, throttled.retryAfterSeconds(), "The refresh should be retried when the interval is over")
+
+	bs.lastRefresh = time.Now().Add(-time.Minute)
+	assert.Nil(t, bs.refreshCache(context.Background()), "A refresh after the interval should fetch Bertha")
+}
+
+func TestShouldNotThrottleRefreshAfterFailedFetch(t *testing.T) {
+	startBerthaMock("happy")
+	bs, err := newBerthaService(berthaMock.URL+berthaPath, &berthaTransformer{}, nil, time.Minute, 0)
+	assert.Nil(t, err)
+	berthaMock.Close()
+	startBerthaMock("unhappy")
+	defer berthaMock.Close()
+	bs.berthaUrl = berthaMock.URL + berthaPath
+	bs.lastRefresh = time.Time{}
+
+	assert.IsType(t, &upstreamError{}, bs.refreshCache(context.Background()), "The fetch should fail")
+	assert.IsType(t, &upstreamError{}, bs.refreshCache(context.Background()), "A failed fetch should be retried without being throttled")
+}
+
+func TestShouldRecordRefreshStats(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	mt := new(MockedTransformer)
+	mt.On("authorToPerson", mock.Anything).Return(person{}, errors.New("malformed biography"))
+	spreadSheetUrl := berthaMock.URL + berthaPath
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+
+	stats := bs.getRefreshStats(context.Background())
+	assert.False(t, stats.lastSuccess.IsZero(), "The refresh should be recorded")
+	assert.Equal(t, 2, stats.fetchedAuthors, "The fetched authors should be counted")
+	assert.Equal(t, 0, stats.transformErrors, "There should be no transform errors")
+
+	bs.transformer = mt
+	refreshed := stats.lastSuccess
+	successes := refreshSuccesses.Count()
+	err = bs.refreshCache(context.Background())
+	assert.IsType(t, &transformError{}, err, "The refresh should fail")
+	stats = bs.getRefreshStats(context.Background())
+	assert.Equal(t, 2, stats.transformErrors, "Every author that cannot be transformed should be counted")
+	assert.True(t, stats.lastSuccess.After(refreshed), "A cache replaced despite transform errors should be fresh")
+	assert.Equal(t, successes+1, refreshSuccesses.Count(), "A cache replaced despite transform errors should be counted as refreshed")
+	assert.Equal(t, 2, stats.previousAuthorsCount, "The authors before the refresh should be counted")
+
+	err = bs.refreshCache(context.Background())
+	assert.IsType(t, &transformError{}, err, "The refresh should fail")
+	assert.Equal(t, 0, bs.getRefreshStats(context.Background()).previousAuthorsCount, "The drop should be measured against the previous refresh")
+}
+
+// startHangingBertha serves the authors once, then holds every call until it is cancelled by the client
+func startHangingBertha(cancelled chan<- struct{}) *httptest.Server {
+	var calls int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			berthaHandlerMock(w, r)
+			return
+		}
+		<-r.Context().Done()
+		cancelled <- struct{}{}
+	}))
+}
+
+func TestShouldTimeOutHungBerthaAndKeepCachedAuthors(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	hungBertha := startHangingBertha(cancelled)
+	defer hungBertha.Close()
+	bs, err := newBerthaService(hungBertha.URL, &berthaTransformer{}, nil, 0, 100*time.Millisecond)
+	assert.Nil(t, err)
+
+	refreshed := make(chan error)
+	go func() { refreshed <- bs.refreshCache(context.Background()) }()
+
+	start := time.Now()
+	assert.Equal(t, 2, bs.getAuthorsCount(context.Background()), "The cached authors should be served during the refresh")
+	assert.True(t, time.Since(start) < 50*time.Millisecond, "The cached authors should not wait for Bertha")
+
+	err = <-refreshed
+	assert.IsType(t, &upstreamError{}, err, "The refresh should fail when Bertha does not answer in time")
+	<-cancelled
+	assert.Equal(t, 2, bs.getAuthorsCount(context.Background()), "The cached authors should be kept")
+}
+
+func TestShouldCancelRefreshWhenCallersGiveUp(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	hungBertha := startHangingBertha(cancelled)
+	defer hungBertha.Close()
+	bs, err := newBerthaService(hungBertha.URL, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	refreshed := make(chan error)
+	go func() { refreshed <- bs.refreshCache(ctx) }()
+	go func() { refreshed <- bs.refreshCache(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-refreshed, "A caller should stop waiting when its context is cancelled")
+	assert.Equal(t, context.Canceled, <-refreshed, "A caller should stop waiting when its context is cancelled")
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("The call to Bertha should be cancelled when no caller waits for it")
+	}
+	assert.Equal(t, 2, bs.getAuthorsCount(context.Background()), "The cached authors should be kept")
+}
+
+func TestShouldShareImagesOfAuthorsWithTheSameHeadshot(t *testing.T) {
+	lucyKellaway := transformedMartinWolf
+	lucyKellaway.Uuid = lucyKellawayUuid
+	lucyKellaway.PrefLabel = "Lucy Kellaway"
+	mt := new(MockedTransformer)
+	mt.On("authorToPerson", mock.Anything).Return(transformedMartinWolf, nil).Once()
+	mt.On("authorToPerson", mock.Anything).Return(lucyKellaway, nil).Once()
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	bs, err := newBerthaService(berthaMock.URL+berthaPath, mt, nil, 0, 0)
+
+	assert.Nil(t, err)
+	assert.Len(t, bs.getImagesUuids(context.Background()), 2, "The headshot should be published once")
+	assert.Equal(t, imageTitle, bs.getImageByUuid(context.Background(), martinWolfImageSetUuid).Title, "The title should not name one of the authors")
+}
+
+func TestShouldReturnLocalisedDescriptionsOfAuthor(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	spreadSheetUrl := berthaMock.URL + berthaPath
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
+
+	assert.Nil(t, err)
+	a := bs.getAuthorByUuid(context.Background(), lucyKellawayUuid)
+	assert.Equal(t, map[string]localisedDescription{"zh": lucyKellawayZh}, a.LocalisedDescriptions, "Lucy Kellaway should have a Chinese biography")
+}
+
 func TestShouldReturnEmptyAuthorWhenAuthorIsNotAvailable(t *testing.T) {
 	startBerthaMock("happy")
 	defer berthaMock.Close()
 	spreadSheetUrl := berthaMock.URL + berthaPath
-	bs, err := newBerthaService(spreadSheetUrl)
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
 
-	bs.getAuthorsCount()
+	bs.getAuthorsCount(context.Background())
 
 	assert.Nil(t, err)
-	a := bs.getAuthorByUuid("7f8bd61a-3575-4d32-a758-0fa41cbcc826")
+	a := bs.getAuthorByUuid(context.Background(), "7f8bd61a-3575-4d32-a758-0fa41cbcc826")
 	assert.Equal(t, person{}, a, "The author should be empty")
 }
 
@@ -113,16 +376,16 @@ func TestShouldReturnErrorWhenBerthaIsUnhappy(t *testing.T) {
 	startBerthaMock("unhappy")
 	defer berthaMock.Close()
 	spreadSheetUrl := berthaMock.URL + berthaPath
-	bs, err := newBerthaService(spreadSheetUrl)
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
 	assert.NotNil(t, err)
 
-	c := bs.getAuthorsCount()
+	c := bs.getAuthorsCount(context.Background())
 	assert.Equal(t, 0, c, "It should return 0")
 
-	authors := bs.getAuthorsUuids()
+	authors := bs.getAuthorsUuids(context.Background())
 	assert.Equal(t, 0, len(authors), "It should return 0 authors")
 
-	a := bs.getAuthorByUuid(martinWolfUuid)
+	a := bs.getAuthorByUuid(context.Background(), martinWolfUuid)
 	assert.Equal(t, person{}, a, "The author should be empty")
 }
 
@@ -130,7 +393,7 @@ func TestCheckConnectivityOfHappyBerta(t *testing.T) {
 	startBerthaMock("happy")
 	defer berthaMock.Close()
 	spreadSheetUrl := berthaMock.URL + berthaPath
-	bs, err := newBerthaService(spreadSheetUrl)
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
 
 	c := bs.checkConnectivity()
 	assert.Nil(t, err)
@@ -141,7 +404,7 @@ func TestCheckConnectivityOfUnhappyBertha(t *testing.T) {
 	startBerthaMock("unhappy")
 	defer berthaMock.Close()
 	spreadSheetUrl := berthaMock.URL + berthaPath
-	bs, err := newBerthaService(spreadSheetUrl)
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
 
 	c := bs.checkConnectivity()
 	assert.NotNil(t, err)
@@ -150,13 +413,33 @@ func TestCheckConnectivityOfUnhappyBertha(t *testing.T) {
 
 func TestCheckConnectivityBerthaOffline(t *testing.T) {
 	spreadSheetUrl := berthaMock.URL + berthaPath
-	bs, err := newBerthaService(spreadSheetUrl)
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
 
 	c := bs.checkConnectivity()
 	assert.NotNil(t, err)
 	assert.NotNil(t, c)
 }
 
+func TestShouldMonitorConnectivityInBackground(t *testing.T) {
+	startBerthaMock("happy")
+	spreadSheetUrl := berthaMock.URL + berthaPath
+	bs, err := newBerthaService(spreadSheetUrl, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+	assert.True(t, bs.getConnectivity(context.Background()).checkedAt.IsZero(), "Connectivity should not be checked yet")
+	berthaMock.Close()
+
+	done := make(chan struct{})
+	go bs.monitorConnectivity(10*time.Millisecond, done)
+	defer close(done)
+	for bs.getConnectivity(context.Background()).consecutiveFailures < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	c := bs.getConnectivity(context.Background())
+	assert.NotNil(t, c.err, "Bertha should be reported as unreachable")
+	assert.Equal(t, 2, bs.getAuthorsCount(context.Background()), "The cached authors should still be served")
+}
+
 func contains(s []string, e string) bool {
 	for _, a := range s {
 		if a == e {
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+
+	log "github.com/Sirupsen/logrus"
 	"github.com/jaytaylor/html2text"
 	"github.com/pborman/uuid"
 )
@@ -8,34 +11,113 @@ import (
 const tmeAuthority = "http://api.ft.com/system/FT-TME"
 
 type berthaTransformer struct {
+	biographyFormat     string
+	descriptionVariants bool
+	summaryMaxLength    int
 }
 
-func (bt *berthaTransformer) authorToPerson(a author) (person, error) {
+func (bt *berthaTransformer) authorToPerson(ctx context.Context, a author) (p person, err error) {
+	_, s := startSpan(ctx, "authorToPerson")
+	s.setAttribute("author.tme_identifier", a.TmeIdentifier)
+	defer func() { s.finish(err) }()
+
 	uuid := uuid.NewMD5(uuid.UUID{}, []byte(a.TmeIdentifier)).String()
-	plainDescription, err := html2text.FromString(a.Biography)
+	format := bt.biographyFormat
+	if isValidBiographyFormat(a.BiographyFormat) {
+		if a.BiographyFormat != "" {
+			format = a.BiographyFormat
+		}
+	} else {
+		log.WithFields(log.Fields{"uuid": uuid, "biography_format": a.BiographyFormat}).Warn("Unsupported biography format, using the default one")
+	}
 
+	d, err := bt.biographyToDescription(uuid, a.Biography, format)
 	if err != nil {
 		return person{}, err
 	}
 
+	var localised map[string]localisedDescription
+	for lang, biography := range a.LocalisedBiographies {
+		ld, err := bt.biographyToDescription(uuid, biography, format)
+		if err != nil {
+			return person{}, err
+		}
+		if localised == nil {
+			localised = map[string]localisedDescription{}
+		}
+		localised[lang] = ld
+	}
+
+	pn := parseName(a.Name)
+
 	altIds := alternativeIdentifiers{
 		UUIDS: []string{uuid},
 		TME:   []string{a.TmeIdentifier},
 	}
 
-	p := person{
+	p = person{
 		Uuid:                   uuid,
 		Name:                   a.Name,
 		PrefLabel:              a.Name,
+		Salutation:             pn.salutation,
+		GivenName:              pn.givenName,
+		FamilyName:             pn.familyName,
+		SortKey:                pn.sortKey(),
+		Aliases:                buildAliases(a.Name, pn, a.Aliases),
+		Role:                   a.Role,
 		EmailAddress:           a.Email,
 		TwitterHandle:          a.TwitterHandle,
 		FacebookProfile:        a.FacebookProfile,
 		LinkedinProfile:        a.LinkedinProfile,
-		Description:            plainDescription,
-		DescriptionXML:         a.Biography,
+		Description:            d.Description,
+		DescriptionXML:         d.DescriptionXML,
+		PlainDescription:       d.PlainDescription,
+		Summary:                d.Summary,
+		LocalisedDescriptions:  localised,
 		ImageUrl:               a.ImageUrl,
+		ImageSet:               newImageSet(a.ImageUrl),
 		AlternativeIdentifiers: altIds,
 	}
 
 	return p, err
 }
+
+func (bt *berthaTransformer) biographyToDescription(uuid string, biography string, format string) (localisedDescription, error) {
+	htmlBiography, err := biographyToHTML(biography, format)
+	if err != nil {
+		return localisedDescription{}, err
+	}
+
+	descriptionXML, report, err := sanitiseBiography(htmlBiography)
+	if err != nil {
+		return localisedDescription{}, err
+	}
+	if !report.isEmpty() {
+		log.WithFields(log.Fields{
+			"uuid":                uuid,
+			"stripped_elements":   report.StrippedElements,
+			"stripped_attributes": report.StrippedAttributes,
+		}).Warn("Stripped unsupported markup from author biography")
+	}
+
+	plainDescription, err := html2text.FromString(descriptionXML)
+	if err != nil {
+		return localisedDescription{}, err
+	}
+
+	d := localisedDescription{
+		Description:    plainDescription,
+		DescriptionXML: descriptionXML,
+	}
+
+	if bt.descriptionVariants {
+		plainWithoutLinks, err := plainTextWithoutLinks(descriptionXML)
+		if err != nil {
+			return localisedDescription{}, err
+		}
+		d.PlainDescription = plainWithoutLinks
+		d.Summary = summarise(plainWithoutLinks, bt.summaryMaxLength)
+	}
+
+	return d, nil
+}
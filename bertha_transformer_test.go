@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,10 +28,20 @@ var someAltIds = alternativeIdentifiers{
 	UUIDS: []string{cartmanUuid},
 }
 
+var cartmanImageSet = &imageSet{
+	UUID: "bff4a99a-2e7d-3ecc-b871-935bf4615810",
+	Members: []imageMember{
+		{UUID: "adb219c1-4a8c-3304-a25f-b47dcad5cc9d", BinaryUrl: anAuthor.ImageUrl},
+	},
+}
+
 var aPerson = person{
 	Uuid:                   cartmanUuid,
 	Name:                   "Eric Cartman",
 	PrefLabel:              "Eric Cartman",
+	GivenName:              "Eric",
+	FamilyName:             "Cartman",
+	SortKey:                "Cartman, Eric",
 	EmailAddress:           "eric.cartman@southpark.cc.com",
 	TwitterHandle:          "@SouthPark",
 	FacebookProfile:        "OfficialCartman",
@@ -38,12 +49,33 @@ var aPerson = person{
 	Description:            aBio,
 	DescriptionXML:         aBioXml,
 	ImageUrl:               "https://upload.wikimedia.org/wikipedia/en/7/77/EricCartman.png",
+	ImageSet:               cartmanImageSet,
 	AlternativeIdentifiers: someAltIds,
 }
 
 func TestShouldTransformAuthorToPersonSucessfully(t *testing.T) {
 	transformer := berthaTransformer{}
-	p, err := transformer.authorToPerson(anAuthor)
+	p, err := transformer.authorToPerson(context.Background(), anAuthor)
 	assert.Nil(t, err)
 	assert.Equal(t, aPerson, p, "The author")
 }
+
+func TestShouldAddDescriptionVariantsWhenEnabled(t *testing.T) {
+	transformer := berthaTransformer{descriptionVariants: true, summaryMaxLength: 60}
+	p, err := transformer.authorToPerson(context.Background(), anAuthor)
+	assert.Nil(t, err)
+	assert.Equal(t, "Eric Theodore Cartman is one of the main characters in the animated television series South Park, created by Matt Stone and Trey Parker, and voiced by Trey Parker.", p.PlainDescription, "The plain description should not contain links")
+	assert.Equal(t, "Eric Theodore Cartman is one of the main characters in the…", p.Summary, "The summary should be truncated")
+}
+
+func TestShouldTransformMarkdownBiography(t *testing.T) {
+	transformer := berthaTransformer{}
+	a := anAuthor
+	a.Biography = "Eric Theodore Cartman is one of the main characters in the animated television series [South Park](https://en.wikipedia.org/wiki/South_Park), created by Matt Stone and Trey Parker, and voiced by Trey Parker."
+	a.BiographyFormat = "markdown"
+
+	p, err := transformer.authorToPerson(context.Background(), a)
+	assert.Nil(t, err)
+	assert.Equal(t, aBioXml, p.DescriptionXML, "The Markdown biography should be converted to body XML")
+	assert.Equal(t, aBio, p.Description, "The Markdown biography should be converted to plain text")
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/russross/blackfriday"
+)
+
+const (
+	biographyFormatHTML     = "html"
+	biographyFormatMarkdown = "markdown"
+	biographyFormatAuto     = "auto"
+)
+
+// Matches anything that looks like an HTML tag, e.g. "<p>" or "</a>"
+var htmlTagRegex = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+
+func isValidBiographyFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "", biographyFormatHTML, biographyFormatMarkdown, biographyFormatAuto:
+		return true
+	}
+	return false
+}
+
+// biographyToHTML converts a biography to HTML according to its format.
+// Biographies are HTML by default, and in auto format the ones without any HTML tag are considered Markdown.
+func biographyToHTML(biography string, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", biographyFormatHTML:
+		return biography, nil
+	case biographyFormatMarkdown:
+		return string(blackfriday.MarkdownCommon([]byte(biography))), nil
+	case biographyFormatAuto:
+		if htmlTagRegex.MatchString(biography) {
+			return biography, nil
+		}
+		return string(blackfriday.MarkdownCommon([]byte(biography))), nil
+	}
+	return "", fmt.Errorf("Unsupported biography format: %s", format)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const markdownBio = "Tim Harford writes **The Undercover Economist** column for the [FT](https://www.ft.com)."
+
+func TestShouldConvertMarkdownBiographyToHTML(t *testing.T) {
+	html, err := biographyToHTML(markdownBio, biographyFormatMarkdown)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>Tim Harford writes <strong>The Undercover Economist</strong> column for the <a href=\"https://www.ft.com\">FT</a>.</p>\n", html, "Markdown should be converted to HTML")
+}
+
+func TestShouldDetectFormatOfBiography(t *testing.T) {
+	html, err := biographyToHTML(markdownBio, biographyFormatAuto)
+	assert.Nil(t, err)
+	assert.Contains(t, html, "<strong>The Undercover Economist</strong>", "A biography without tags should be Markdown")
+
+	html, err = biographyToHTML(aBioXml, biographyFormatAuto)
+	assert.Nil(t, err)
+	assert.Equal(t, aBioXml, html, "A biography with tags should be HTML")
+}
+
+func TestShouldLeaveHTMLBiographyAsItIs(t *testing.T) {
+	html, err := biographyToHTML(markdownBio, "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, markdownBio, html, "Biographies should be HTML by default")
+}
+
+func TestShouldFailForUnsupportedBiographyFormat(t *testing.T) {
+	_, err := biographyToHTML(markdownBio, "textile")
+
+	assert.NotNil(t, err)
+	assert.False(t, isValidBiographyFormat("textile"), "Textile should not be supported")
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Elements allowed in FT body XML, with the attributes each of them may keep
+var allowedElements = map[string][]string{
+	"p":      nil,
+	"br":     nil,
+	"ul":     nil,
+	"ol":     nil,
+	"li":     nil,
+	"strong": nil,
+	"em":     nil,
+	"sub":    nil,
+	"sup":    nil,
+	"a":      {"href", "title"},
+}
+
+// Elements with an FT body XML equivalent
+var renamedElements = map[string]string{
+	"b":   "strong",
+	"i":   "em",
+	"div": "p",
+}
+
+// Elements removed together with their content
+var droppedElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"head":     true,
+	"title":    true,
+	"noscript": true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+}
+
+// Elements that cannot be nested in a paragraph or an inline element. A list item outside a list is a block too.
+var blockElements = map[string]bool{
+	"p":  true,
+	"ul": true,
+	"ol": true,
+	"li": true,
+}
+
+var allowedLinkSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// sanitisationReport lists the markup that has been stripped from a biography, e.g. "script" or "span@style"
+type sanitisationReport struct {
+	StrippedElements   []string
+	StrippedAttributes []string
+}
+
+func (r sanitisationReport) isEmpty() bool {
+	return len(r.StrippedElements) == 0 && len(r.StrippedAttributes) == 0
+}
+
+type biographySanitiser struct {
+	strippedElements   map[string]bool
+	strippedAttributes map[string]bool
+}
+
+// sanitiseBiography converts the HTML curated in Bertha into FT body XML.
+// Only inline formatting, links, paragraphs and lists are kept, unknown elements are unwrapped
+// and loose text is wrapped into paragraphs.
+func sanitiseBiography(biography string) (string, sanitisationReport, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(biography), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", sanitisationReport{}, err
+	}
+
+	s := &biographySanitiser{
+		strippedElements:   map[string]bool{},
+		strippedAttributes: map[string]bool{},
+	}
+
+	var cleaned []*html.Node
+	for _, n := range nodes {
+		cleaned = append(cleaned, s.clean(n)...)
+	}
+
+	var buf bytes.Buffer
+	for _, n := range wrapInParagraphs(cleaned) {
+		renderXML(&buf, n)
+	}
+	return buf.String(), s.report(), nil
+}
+
+func (s *biographySanitiser) clean(n *html.Node) []*html.Node {
+	switch n.Type {
+	case html.TextNode:
+		return []*html.Node{{Type: html.TextNode, Data: n.Data}}
+	case html.ElementNode:
+		return s.cleanElement(n)
+	case html.CommentNode, html.DoctypeNode:
+		return nil
+	}
+	return s.cleanChildren(n)
+}
+
+func (s *biographySanitiser) cleanElement(n *html.Node) []*html.Node {
+	name := n.Data
+	if droppedElements[name] {
+		s.strippedElements[name] = true
+		return nil
+	}
+	if renamed, found := renamedElements[name]; found {
+		name = renamed
+	}
+	allowedAttrs, allowed := allowedElements[name]
+	if !allowed {
+		s.strippedElements[name] = true
+		return s.cleanChildren(n)
+	}
+
+	el := &html.Node{Type: html.ElementNode, Data: name}
+	for _, attr := range n.Attr {
+		if isAllowedAttribute(allowedAttrs, attr.Key) && (attr.Key != "href" || isSafeLink(attr.Val)) {
+			el.Attr = append(el.Attr, html.Attribute{Key: attr.Key, Val: attr.Val})
+		} else {
+			s.strippedAttributes[n.Data+"@"+attr.Key] = true
+		}
+	}
+
+	if name == "a" && !hasAttr(el, "href") {
+		return s.cleanChildren(n)
+	}
+
+	children := s.cleanChildren(n)
+	if name == "ul" || name == "ol" {
+		children = withoutWhitespace(children)
+	}
+	if !blockElements[name] && containsBlocks(children) {
+		children = unwrapBlocks(children)
+	}
+	if !blockElements[name] && name != "br" && len(withoutWhitespace(children)) == 0 {
+		return children
+	}
+	if name == "p" {
+		if containsBlocks(children) {
+			return wrapInParagraphs(children)
+		}
+		children = trimText(children)
+		if len(children) == 0 {
+			return nil
+		}
+	}
+	for _, c := range children {
+		el.AppendChild(c)
+	}
+	return []*html.Node{el}
+}
+
+func (s *biographySanitiser) cleanChildren(n *html.Node) []*html.Node {
+	var cleaned []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		cleaned = append(cleaned, s.clean(c)...)
+	}
+	return cleaned
+}
+
+func (s *biographySanitiser) report() sanitisationReport {
+	return sanitisationReport{
+		StrippedElements:   sortedKeys(s.strippedElements),
+		StrippedAttributes: sortedKeys(s.strippedAttributes),
+	}
+}
+
+// wrapInParagraphs groups consecutive inline nodes into paragraphs, leaving block elements as they are
+func wrapInParagraphs(nodes []*html.Node) []*html.Node {
+	var blocks []*html.Node
+	var inline []*html.Node
+
+	flush := func() {
+		inline = trimText(inline)
+		if len(inline) > 0 {
+			p := &html.Node{Type: html.ElementNode, Data: "p"}
+			for _, n := range inline {
+				p.AppendChild(n)
+			}
+			blocks = append(blocks, p)
+		}
+		inline = nil
+	}
+
+	for _, n := range nodes {
+		if n.Type == html.ElementNode && n.Data == "li" {
+			flush()
+			blocks = append(blocks, wrapInParagraphs(detachChildren(n))...)
+		} else if n.Type == html.ElementNode && blockElements[n.Data] {
+			flush()
+			blocks = append(blocks, n)
+		} else {
+			inline = append(inline, n)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// trimText removes the leading and trailing whitespace and line breaks of a sequence of inline nodes
+func trimText(nodes []*html.Node) []*html.Node {
+	for len(nodes) > 0 {
+		first := nodes[0]
+		if first.Type == html.TextNode {
+			first.Data = strings.TrimLeftFunc(first.Data, unicode.IsSpace)
+		}
+		if !isBlank(first) {
+			break
+		}
+		nodes = nodes[1:]
+	}
+	for len(nodes) > 0 {
+		last := nodes[len(nodes)-1]
+		if last.Type == html.TextNode {
+			last.Data = strings.TrimRightFunc(last.Data, unicode.IsSpace)
+		}
+		if !isBlank(last) {
+			break
+		}
+		nodes = nodes[:len(nodes)-1]
+	}
+	return nodes
+}
+
+func isBlank(n *html.Node) bool {
+	return (n.Type == html.TextNode && n.Data == "") || (n.Type == html.ElementNode && n.Data == "br")
+}
+
+func withoutWhitespace(nodes []*html.Node) []*html.Node {
+	var kept []*html.Node
+	for _, n := range nodes {
+		if n.Type != html.TextNode || strings.TrimSpace(n.Data) != "" {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+func containsBlocks(nodes []*html.Node) bool {
+	for _, n := range nodes {
+		if n.Type == html.ElementNode && blockElements[n.Data] {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapBlocks replaces the blocks nested in an inline element by their content, separated by line breaks
+func unwrapBlocks(nodes []*html.Node) []*html.Node {
+	var unwrapped []*html.Node
+	for _, n := range nodes {
+		if n.Type != html.ElementNode || !blockElements[n.Data] {
+			unwrapped = append(unwrapped, n)
+			continue
+		}
+		if len(unwrapped) > 0 {
+			unwrapped = append(unwrapped, &html.Node{Type: html.ElementNode, Data: "br"})
+		}
+		unwrapped = append(unwrapped, unwrapBlocks(detachChildren(n))...)
+	}
+	return unwrapped
+}
+
+func detachChildren(n *html.Node) []*html.Node {
+	var children []*html.Node
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		n.RemoveChild(c)
+		children = append(children, c)
+	}
+	return children
+}
+
+func isAllowedAttribute(allowed []string, key string) bool {
+	for _, a := range allowed {
+		if a == key {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func isSafeLink(href string) bool {
+	u, err := url.Parse(strings.TrimSpace(href))
+	return err == nil && allowedLinkSchemes[strings.ToLower(u.Scheme)]
+}
+
+func renderXML(buf *bytes.Buffer, n *html.Node) {
+	if n.Type == html.TextNode {
+		buf.WriteString(html.EscapeString(n.Data))
+		return
+	}
+
+	buf.WriteString("<" + n.Data)
+	for _, attr := range n.Attr {
+		buf.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+	if n.FirstChild == nil && n.Data == "br" {
+		buf.WriteString("/>")
+		return
+	}
+	buf.WriteString(">")
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		renderXML(buf, c)
+	}
+	buf.WriteString("</" + n.Data + ">")
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGoldenFiles = flag.Bool("update", false, "Update the golden files of the biography sanitiser")
+
+const biographiesPath = "test-resources/biographies"
+
+func TestShouldSanitiseBiographiesAsGoldenFiles(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(biographiesPath, "*.html"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, inputs, "There should be biographies to sanitise")
+
+	for _, input := range inputs {
+		bio, err := ioutil.ReadFile(input)
+		assert.Nil(t, err)
+
+		actual, _, err := sanitiseBiography(string(bio))
+		assert.Nil(t, err)
+
+		golden := strings.TrimSuffix(input, ".html") + ".xml"
+		if *updateGoldenFiles {
+			ioutil.WriteFile(golden, []byte(actual), 0644)
+		}
+		expected, err := ioutil.ReadFile(golden)
+		assert.Nil(t, err)
+		assert.Equal(t, string(expected), actual, "Unexpected body XML for "+input)
+	}
+}
+
+func TestShouldReportStrippedMarkup(t *testing.T) {
+	bio := `<script>alert(1)</script><p style="color:red"><span>Gillian Tett</span> on <a href="javascript:steal()">FT</a></p>`
+	xml, report, err := sanitiseBiography(bio)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>Gillian Tett on FT</p>", xml, "Unexpected body XML")
+	assert.Equal(t, []string{"script", "span"}, report.StrippedElements, "Script and span should be reported")
+	assert.Equal(t, []string{"a@href", "p@style"}, report.StrippedAttributes, "Unsafe link and style should be reported")
+}
+
+func TestShouldNotReportCleanBiographies(t *testing.T) {
+	xml, report, err := sanitiseBiography(aBioXml)
+
+	assert.Nil(t, err)
+	assert.Equal(t, aBioXml, xml, "A clean biography should be left as it is")
+	assert.True(t, report.isEmpty(), "Nothing should be reported")
+}
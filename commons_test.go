@@ -26,14 +26,29 @@ var transformedMartinWolf = person{
 	Uuid:                   martinWolfUuid,
 	Name:                   "Martin Wolf",
 	PrefLabel:              "Martin Wolf",
+	GivenName:              "Martin",
+	FamilyName:             "Wolf",
+	SortKey:                "Wolf, Martin",
+	Role:                   "Columnist",
 	EmailAddress:           "martin.wolf@ft.com",
 	TwitterHandle:          "@martinwolf_",
 	Description:            "Martin Wolf is chief economics commentator at the Financial Times, London.",
 	DescriptionXML:         `<p>Martin Wolf is chief economics commentator at the Financial Times, London.</p>`,
 	ImageUrl:               "https://next-geebee.ft.com/image/v1/images/raw/fthead:martin-wolf?source=next",
+	ImageSet:               martinWolfImageSet,
 	AlternativeIdentifiers: martinWolfAltIds,
 }
 
+var martinWolfImageSetUuid = "d3ea051a-c8b6-3f10-939a-61e3864fbe59"
+var martinWolfImageUuid = "091a55d9-fc81-3ce7-8c28-419c392f2a47"
+
+var martinWolfImageSet = &imageSet{
+	UUID: martinWolfImageSetUuid,
+	Members: []imageMember{
+		{UUID: martinWolfImageUuid, BinaryUrl: martinWolf.ImageUrl},
+	},
+}
+
 var martinWolfAltIds = alternativeIdentifiers{
 	TME:   []string{martinWolf.TmeIdentifier},
 	UUIDS: []string{martinWolfUuid},
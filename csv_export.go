@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+const csvMediaType = "text/csv"
+
+var csvHeader = []string{
+	"uuid",
+	"prefLabel",
+	"emailAddress",
+	"twitterHandle",
+	"facebookProfile",
+	"linkedinProfile",
+	"tmeIdentifiers",
+	"imageUrl",
+	"description",
+}
+
+// writeCSV flattens the authors into CSV rows, one per author, joining multiple TME identifiers with ";"
+func writeCSV(w io.Writer, people []person) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, p := range people {
+		row := escapeFormulas([]string{
+			p.Uuid,
+			p.PrefLabel,
+			p.EmailAddress,
+			p.TwitterHandle,
+			p.FacebookProfile,
+			p.LinkedinProfile,
+			strings.Join(p.AlternativeIdentifiers.TME, ";"),
+			p.ImageUrl,
+			strings.TrimSpace(p.Description),
+		})
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// escapeFormulas prefixes with a quote the values a spreadsheet would evaluate as a formula, e.g. "=HYPERLINK(...)"
+// or a twitter handle starting with "@"
+func escapeFormulas(values []string) []string {
+	for i, v := range values {
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			values[i] = "'" + v
+		}
+	}
+	return values
+}
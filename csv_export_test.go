@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldEscapeFormulasInCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeCSV(&buf, []person{{
+		Uuid:            martinWolfUuid,
+		PrefLabel:       "=HYPERLINK(\"http://evil.com\",\"Martin Wolf\")",
+		TwitterHandle:   "@martinwolf_",
+		FacebookProfile: "+44 20 7873 3000",
+		LinkedinProfile: "-martin-wolf",
+		ImageUrl:        "\thttp://example.com/image.png",
+		Description:     "Martin Wolf = chief economics commentator",
+	}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, csvHeaderLine+martinWolfUuid+`,"'=HYPERLINK(""http://evil.com"",""Martin Wolf"")",,'@martinwolf_,'+44 20 7873 3000,'-martin-wolf,,'`+"\t"+`http://example.com/image.png,Martin Wolf = chief economics commentator`+"\n", buf.String())
+}
+
+const csvHeaderLine = "uuid,prefLabel,emailAddress,twitterHandle,facebookProfile,linkedinProfile,tmeIdentifiers,imageUrl,description\n"
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jaytaylor/html2text"
+)
+
+const ellipsis = "…"
+
+// Matches the link tags of sanitised body XML, leaving their text in place
+var linkTagRegex = regexp.MustCompile(`</?a(\s[^>]*)?>`)
+
+// plainTextWithoutLinks converts sanitised body XML into plain text without the link URLs added by html2text
+func plainTextWithoutLinks(bodyXML string) (string, error) {
+	return html2text.FromString(linkTagRegex.ReplaceAllString(bodyXML, ""))
+}
+
+// summarise returns the first sentence of a plain text description.
+// Sentences longer than maxLength characters are cut at the last word boundary and end with an ellipsis.
+func summarise(text string, maxLength int) string {
+	summary := firstSentence(strings.Join(strings.Fields(text), " "))
+	if maxLength <= 0 || utf8.RuneCountInString(summary) <= maxLength {
+		return summary
+	}
+
+	runes := []rune(summary)
+	cut := maxLength - utf8.RuneCountInString(ellipsis)
+	if cut <= 0 {
+		return string(runes[:maxLength])
+	}
+	end := cut
+	for end > 0 && !unicode.IsSpace(runes[end]) {
+		end--
+	}
+	if end == 0 {
+		end = cut
+	}
+	return strings.TrimRightFunc(string(runes[:end]), isTrailingPunctuation) + ellipsis
+}
+
+// firstSentence ends at the first full stop, question or exclamation mark followed by a capitalised word,
+// ignoring the dots of salutations such as "Dr." and of initials such as "J."
+func firstSentence(text string) string {
+	words := strings.Split(text, " ")
+	for i := 0; i < len(words)-1; i++ {
+		w := words[i]
+		if !strings.HasSuffix(w, ".") && !strings.HasSuffix(w, "?") && !strings.HasSuffix(w, "!") {
+			continue
+		}
+		next, _ := utf8.DecodeRuneInString(words[i+1])
+		if !unicode.IsUpper(next) {
+			continue
+		}
+		if strings.HasSuffix(w, ".") && isAbbreviation(w) {
+			continue
+		}
+		return strings.Join(words[:i+1], " ")
+	}
+	return text
+}
+
+func isAbbreviation(word string) bool {
+	token := normaliseNameToken(strings.TrimLeftFunc(word, unicode.IsPunct))
+	_, salutation := salutations[token]
+	return salutation || utf8.RuneCountInString(token) == 1
+}
+
+func isTrailingPunctuation(r rune) bool {
+	return unicode.IsSpace(r) || r == ',' || r == ';' || r == ':' || r == '-'
+}
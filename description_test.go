@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldRemoveLinksFromPlainText(t *testing.T) {
+	text, err := plainTextWithoutLinks(aBioXml)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Eric Theodore Cartman is one of the main characters in the animated television series South Park, created by Matt Stone and Trey Parker, and voiced by Trey Parker.", text, "Links should be removed")
+}
+
+func TestShouldSummariseToFirstSentence(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected string
+	}{
+		{"Martin Wolf is chief economics commentator. He was awarded the CBE in 2000.", "Martin Wolf is chief economics commentator."},
+		{"Dr. John Smith joined the FT in 2001. He covers markets.", "Dr. John Smith joined the FT in 2001."},
+		{"John F. Kennedy wrote for us once! Really.", "John F. Kennedy wrote for us once!"},
+		{"The U.S. economy is his beat.", "The U.S. economy is his beat."},
+		{"A single   sentence\nwithout a full stop", "A single sentence without a full stop"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, summarise(test.text, 0), "Unexpected summary of "+test.text)
+	}
+}
+
+func TestShouldTruncateSummaryAtWordBoundary(t *testing.T) {
+	text := "Lucy Kellaway is an Associate Editor and management columnist of the FT, poking fun at management fads."
+
+	assert.Equal(t, "Lucy Kellaway is an Associate Editor…", summarise(text, 40), "The summary should not cut words")
+	assert.Equal(t, "Lucy Kellaway is an Associate Editor and management columnist of the FT…", summarise(text, 75), "Trailing punctuation should be removed")
+	assert.Equal(t, "Supercalifragilisti…", summarise("Supercalifragilisticexpialidocious", 20), "A single long word should be cut")
+	assert.Equal(t, text, summarise(text, len(text)), "Short enough summaries should not be truncated")
+}
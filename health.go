@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Financial-Times/go-fthealth/v1a"
+)
+
+const runbookUrl = "https://sites.google.com/a/ft.com/ft-technology-service-transition/home/run-book-library/curated-authors-transformer"
+
+// healthConfig holds the thresholds of the health checks on the cached authors. A zero threshold disables its check,
+// except for maxTransformErrorRate where it tolerates no transform error. The cache freshness check fails until
+// the first refresh whatever maxCacheAge.
+type healthConfig struct {
+	maxCacheAge           time.Duration
+	maxCountDropPercent   float64
+	maxTransformErrorRate float64
+	maxBerthaLatency      time.Duration
+
+	maxConnectivityFailures int
+}
+
+func (ah *authorHandler) CacheFreshnessCheck() v1a.Check {
+	return v1a.Check{
+		BusinessImpact:   "Curated author data may be out of date",
+		Name:             "Check freshness of the cached authors",
+		PanicGuide:       runbookUrl + "#cache-freshness",
+		Severity:         2,
+		TechnicalSummary: "The authors have not been fetched from Bertha for longer than the maximum cache age",
+		Checker:          ah.cacheFreshnessChecker,
+	}
+}
+
+func (ah *authorHandler) cacheFreshnessChecker() (string, error) {
+	stats := ah.authorsService.getRefreshStats(context.Background())
+	if stats.lastSuccess.IsZero() {
+		return "The authors have never been refreshed", fmt.Errorf("No successful refresh from Bertha")
+	}
+	age := time.Since(stats.lastSuccess) / time.Second * time.Second
+	if ah.health.maxCacheAge > 0 && age > ah.health.maxCacheAge {
+		return "The cached authors are stale", fmt.Errorf("Last successful refresh %v ago, more than %v", age, ah.health.maxCacheAge)
+	}
+	return fmt.Sprintf("Last successful refresh %v ago", age), nil
+}
+
+func (ah *authorHandler) AuthorsCountCheck() v1a.Check {
+	return v1a.Check{
+		BusinessImpact:   "Curated authors may be missing",
+		Name:             "Check number of cached authors",
+		PanicGuide:       runbookUrl + "#authors-count",
+		Severity:         2,
+		TechnicalSummary: "There are no authors or their number dropped sharply since the previous refresh, check the Bertha sheet for deleted rows",
+		Checker:          ah.authorsCountChecker,
+	}
+}
+
+func (ah *authorHandler) authorsCountChecker() (string, error) {
+	count := ah.authorsService.getAuthorsCount(context.Background())
+	if count == 0 {
+		return "There are no authors", fmt.Errorf("No authors in cache")
+	}
+	previous := ah.authorsService.getRefreshStats(context.Background()).previousAuthorsCount
+	if previous > 0 && ah.health.maxCountDropPercent > 0 {
+		drop := float64(previous-count) * 100 / float64(previous)
+		if drop > ah.health.maxCountDropPercent {
+			return "The number of authors dropped", fmt.Errorf("%d authors instead of %d before the last refresh, a drop of %.0f%%", count, previous, drop)
+		}
+	}
+	return fmt.Sprintf("%d authors", count), nil
+}
+
+func (ah *authorHandler) TransformErrorsCheck() v1a.Check {
+	return v1a.Check{
+		BusinessImpact:   "Curated author data cannot be refreshed",
+		Name:             "Check transformation of the authors",
+		PanicGuide:       runbookUrl + "#transform-errors",
+		Severity:         2,
+		TechnicalSummary: "Some authors curated in Bertha cannot be transformed, the logs name their TME identifiers",
+		Checker:          ah.transformErrorsChecker,
+	}
+}
+
+func (ah *authorHandler) transformErrorsChecker() (string, error) {
+	stats := ah.authorsService.getRefreshStats(context.Background())
+	if stats.fetchedAuthors == 0 {
+		return "No authors transformed", nil
+	}
+	rate := float64(stats.transformErrors) / float64(stats.fetchedAuthors)
+	if stats.transformErrors > 0 && rate > ah.health.maxTransformErrorRate {
+		return "Some authors cannot be transformed", fmt.Errorf("%d of %d authors cannot be transformed", stats.transformErrors, stats.fetchedAuthors)
+	}
+	return fmt.Sprintf("%d of %d authors cannot be transformed", stats.transformErrors, stats.fetchedAuthors), nil
+}
+
+func (ah *authorHandler) BerthaLatencyCheck() v1a.Check {
+	return v1a.Check{
+		BusinessImpact:   "Refreshes of the curated authors are slow",
+		Name:             "Check latency of Bertha",
+		PanicGuide:       runbookUrl + "#bertha-latency",
+		Severity:         3,
+		TechnicalSummary: "Bertha answered slower than the maximum latency on the last call",
+		Checker:          ah.berthaLatencyChecker,
+	}
+}
+
+func (ah *authorHandler) berthaLatencyChecker() (string, error) {
+	latency := ah.authorsService.getRefreshStats(context.Background()).berthaLatency
+	if ah.health.maxBerthaLatency > 0 && latency > ah.health.maxBerthaLatency {
+		return "Bertha is slow", fmt.Errorf("Bertha answered in %v, more than %v", latency, ah.health.maxBerthaLatency)
+	}
+	return fmt.Sprintf("Bertha answered in %v", latency), nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testHealthConfig = healthConfig{
+	maxCacheAge:           time.Hour,
+	maxCountDropPercent:   20,
+	maxTransformErrorRate: 0.1,
+	maxBerthaLatency:      time.Second,
+}
+
+func TestCacheFreshnessCheck(t *testing.T) {
+	var tests = []struct {
+		lastSuccess time.Time
+		healthy     bool
+	}{
+		{time.Now().Add(-time.Minute), true},
+		{time.Now().Add(-2 * time.Hour), false},
+		{time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		mbs := new(MockedBerthaService)
+		mbs.On("getRefreshStats").Return(refreshStats{lastSuccess: test.lastSuccess})
+		ah := newAuthorHandler(mbs, nil, testHealthConfig)
+
+		_, err := ah.CacheFreshnessCheck().Checker()
+		assert.Equal(t, test.healthy, err == nil, "Unexpected freshness for a refresh at %v", test.lastSuccess)
+	}
+}
+
+func TestAuthorsCountCheck(t *testing.T) {
+	var tests = []struct {
+		count    int
+		previous int
+		healthy  bool
+	}{
+		{100, 100, true},
+		{85, 100, true},
+		{75, 100, false},
+		{10, 0, true},
+		{0, 0, false},
+	}
+
+	for _, test := range tests {
+		mbs := new(MockedBerthaService)
+		mbs.On("getAuthorsCount").Return(test.count)
+		mbs.On("getRefreshStats").Return(refreshStats{previousAuthorsCount: test.previous})
+		ah := newAuthorHandler(mbs, nil, testHealthConfig)
+
+		_, err := ah.AuthorsCountCheck().Checker()
+		assert.Equal(t, test.healthy, err == nil, "Unexpected outcome for %d authors after %d", test.count, test.previous)
+	}
+}
+
+func TestTransformErrorsCheck(t *testing.T) {
+	var tests = []struct {
+		errors  int
+		fetched int
+		healthy bool
+	}{
+		{0, 100, true},
+		{5, 100, true},
+		{20, 100, false},
+		{0, 0, true},
+	}
+
+	for _, test := range tests {
+		mbs := new(MockedBerthaService)
+		mbs.On("getRefreshStats").Return(refreshStats{transformErrors: test.errors, fetchedAuthors: test.fetched})
+		ah := newAuthorHandler(mbs, nil, testHealthConfig)
+
+		_, err := ah.TransformErrorsCheck().Checker()
+		assert.Equal(t, test.healthy, err == nil, "Unexpected outcome for %d errors out of %d authors", test.errors, test.fetched)
+	}
+}
+
+func TestBerthaLatencyCheck(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getRefreshStats").Return(refreshStats{berthaLatency: 3 * time.Second})
+	ah := newAuthorHandler(mbs, nil, testHealthConfig)
+
+	_, err := ah.BerthaLatencyCheck().Checker()
+	assert.NotNil(t, err, "A slow Bertha should fail the check")
+}
+
+func TestGoodToGoShouldDependOnCachedAuthorsOnly(t *testing.T) {
+	bertaDown := errors.New("Bertha is down")
+	var tests = []struct {
+		connectivity connectivityStatus
+		count        int
+		lastSuccess  time.Time
+		status       int
+	}{
+		{connectivityStatus{}, 2, time.Now(), http.StatusOK},
+		{connectivityStatus{err: bertaDown, consecutiveFailures: 3, checkedAt: time.Now()}, 2, time.Now(), http.StatusOK},
+		{connectivityStatus{checkedAt: time.Now()}, 2, time.Now().Add(-2 * time.Hour), http.StatusOK},
+		{connectivityStatus{checkedAt: time.Now()}, 0, time.Now(), http.StatusServiceUnavailable},
+	}
+
+	hc := testHealthConfig
+	hc.maxConnectivityFailures = 3
+	for _, test := range tests {
+		mbs := new(MockedBerthaService)
+		mbs.On("getConnectivity").Return(test.connectivity)
+		mbs.On("getAuthorsCount").Return(test.count)
+		mbs.On("getRefreshStats").Return(refreshStats{lastSuccess: test.lastSuccess})
+		ah := newAuthorHandler(mbs, nil, hc)
+
+		w := httptest.NewRecorder()
+		ah.GoodToGo(w, httptest.NewRequest("GET", "/__gtg", nil))
+		assert.Equal(t, test.status, w.Code, "Unexpected GTG status for %+v", test)
+	}
+}
+
+func TestConnectivityCheckShouldTolerateFewFailures(t *testing.T) {
+	hc := testHealthConfig
+	hc.maxConnectivityFailures = 3
+	for failures, healthy := range map[int]bool{1: true, 2: true, 3: false} {
+		mbs := new(MockedBerthaService)
+		mbs.On("getConnectivity").Return(connectivityStatus{err: errors.New("Bertha is down"), consecutiveFailures: failures, checkedAt: time.Now()})
+		ah := newAuthorHandler(mbs, nil, hc)
+
+		_, err := ah.HealthCheck().Checker()
+		assert.Equal(t, healthy, err == nil, "Unexpected outcome after %d consecutive failures", failures)
+	}
+}
+
+func TestConnectivityCheckShouldReportBackgroundResult(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getConnectivity").Return(connectivityStatus{err: errors.New("Bertha is down"), consecutiveFailures: 1, checkedAt: time.Now()})
+	ah := newAuthorHandler(mbs, nil, testHealthConfig)
+
+	_, err := ah.HealthCheck().Checker()
+	assert.EqualError(t, err, "Bertha is down", "The last background check should be reported")
+}
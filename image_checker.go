@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This struct records the outcome of checking the image of an author
+type imageStatus struct {
+	AuthorUuid    string `json:"authorUuid"`
+	ImageUrl      string `json:"imageUrl"`
+	StatusCode    int    `json:"statusCode,omitempty"`
+	ContentType   string `json:"contentType,omitempty"`
+	ContentLength int64  `json:"contentLength,omitempty"`
+	Error         string `json:"error,omitempty"`
+	Broken        bool   `json:"broken"`
+}
+
+type imageChecker struct {
+	client      *http.Client
+	concurrency int
+}
+
+func newImageChecker(concurrency int, timeout time.Duration) *imageChecker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &imageChecker{
+		client:      &http.Client{Timeout: timeout},
+		concurrency: concurrency,
+	}
+}
+
+// check verifies the images of the given authors, running at most concurrency requests at the same time
+func (ic *imageChecker) check(authors []person) []imageStatus {
+	var toCheck []person
+	for _, p := range authors {
+		if p.ImageUrl != "" {
+			toCheck = append(toCheck, p)
+		}
+	}
+
+	statuses := make([]imageStatus, len(toCheck))
+	sem := make(chan struct{}, ic.concurrency)
+	var wg sync.WaitGroup
+	for i, p := range toCheck {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, p person) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			statuses[i] = ic.checkImage(p.Uuid, p.ImageUrl)
+		}(i, p)
+	}
+	wg.Wait()
+	return statuses
+}
+
+func (ic *imageChecker) checkImage(authorUuid string, imageUrl string) imageStatus {
+	status := imageStatus{AuthorUuid: authorUuid, ImageUrl: imageUrl}
+
+	resp, err := ic.client.Head(imageUrl)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		resp, err = ic.client.Get(imageUrl)
+	}
+	if err != nil {
+		status.Error = err.Error()
+		status.Broken = true
+		return status
+	}
+	defer resp.Body.Close()
+
+	status.StatusCode = resp.StatusCode
+	status.ContentType = resp.Header.Get("Content-Type")
+	if resp.ContentLength > 0 {
+		status.ContentLength = resp.ContentLength
+	}
+	status.Broken = resp.StatusCode != http.StatusOK || !strings.HasPrefix(status.ContentType, "image/")
+	return status
+}
+
+func brokenImages(statuses []imageStatus) []imageStatus {
+	broken := []imageStatus{}
+	for _, s := range statuses {
+		if s.Broken {
+			broken = append(broken, s)
+		}
+	}
+	return broken
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startImagesMock() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/martin-wolf.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", "2048")
+	})
+	mux.HandleFunc("/get-only.jpg", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+	})
+	mux.HandleFunc("/not-an-image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestShouldCheckImagesOfAuthors(t *testing.T) {
+	images := startImagesMock()
+	defer images.Close()
+
+	authors := []person{
+		{Uuid: "1", ImageUrl: images.URL + "/martin-wolf.png"},
+		{Uuid: "2", ImageUrl: images.URL + "/get-only.jpg"},
+		{Uuid: "3", ImageUrl: images.URL + "/not-an-image"},
+		{Uuid: "4", ImageUrl: images.URL + "/missing.png"},
+		{Uuid: "5"},
+	}
+	statuses := newImageChecker(2, time.Second).check(authors)
+
+	assert.Equal(t, 4, len(statuses), "Authors without image should not be checked")
+	assert.Equal(t, imageStatus{AuthorUuid: "1", ImageUrl: authors[0].ImageUrl, StatusCode: 200, ContentType: "image/png", ContentLength: 2048}, statuses[0])
+	assert.False(t, statuses[1].Broken, "Images should be fetched when HEAD is not allowed")
+	assert.True(t, statuses[2].Broken, "Non image content should be broken")
+	assert.True(t, statuses[3].Broken, "Missing images should be broken")
+	assert.Equal(t, 404, statuses[3].StatusCode)
+}
+
+func TestShouldReportUnreachableImagesAsBroken(t *testing.T) {
+	images := startImagesMock()
+	url := images.URL + "/martin-wolf.png"
+	images.Close()
+
+	statuses := newImageChecker(1, time.Second).check([]person{{Uuid: "1", ImageUrl: url}})
+
+	assert.True(t, statuses[0].Broken, "Unreachable images should be broken")
+	assert.NotEmpty(t, statuses[0].Error, "The connection error should be recorded")
+}
+
+func TestShouldFilterBrokenImages(t *testing.T) {
+	statuses := []imageStatus{{AuthorUuid: "1"}, {AuthorUuid: "2", Broken: true}}
+	assert.Equal(t, []imageStatus{{AuthorUuid: "2", Broken: true}}, brokenImages(statuses))
+	assert.Equal(t, []imageStatus{}, brokenImages(nil), "There should be no broken images")
+}
@@ -0,0 +1,61 @@
+package main
+
+import "github.com/pborman/uuid"
+
+const (
+	imageSetType = "ImageSet"
+	imageType    = "Image"
+)
+
+// The title of every image, which does not name the author as several authors may share a headshot
+const imageTitle = "Author headshot"
+
+// This struct references the image set of an author headshot
+type imageSet struct {
+	UUID    string        `json:"uuid"`
+	Members []imageMember `json:"members"`
+}
+
+type imageMember struct {
+	UUID      string `json:"uuid"`
+	BinaryUrl string `json:"binaryUrl"`
+}
+
+// This struct reflects the metadata of an image or image set published alongside the authors
+type imageContent struct {
+	UUID      string        `json:"uuid"`
+	Type      string        `json:"type"`
+	Title     string        `json:"title"`
+	BinaryUrl string        `json:"binaryUrl,omitempty"`
+	Members   []imageMember `json:"members,omitempty"`
+}
+
+// newImageSet derives the image UUID from the image URL and the image set UUID from the image UUID,
+// so that the same headshot always gets the same identifiers
+func newImageSet(imageUrl string) *imageSet {
+	if imageUrl == "" {
+		return nil
+	}
+	imageUuid := uuid.NewMD5(uuid.NameSpace_URL, []byte(imageUrl))
+	imageSetUuid := uuid.NewMD5(imageUuid, []byte(imageSetType))
+	return &imageSet{
+		UUID: imageSetUuid.String(),
+		Members: []imageMember{
+			{UUID: imageUuid.String(), BinaryUrl: imageUrl},
+		},
+	}
+}
+
+// imageContents returns the metadata of the image set of a person and of its members
+func imageContents(p person) []imageContent {
+	if p.ImageSet == nil {
+		return nil
+	}
+	contents := []imageContent{
+		{UUID: p.ImageSet.UUID, Type: imageSetType, Title: imageTitle, Members: p.ImageSet.Members},
+	}
+	for _, m := range p.ImageSet.Members {
+		contents = append(contents, imageContent{UUID: m.UUID, Type: imageType, Title: imageTitle, BinaryUrl: m.BinaryUrl})
+	}
+	return contents
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldDeriveImageSetFromImageUrl(t *testing.T) {
+	assert.Equal(t, martinWolfImageSet, newImageSet(martinWolf.ImageUrl), "The image set should be derived from the image URL")
+	assert.Equal(t, newImageSet(martinWolf.ImageUrl), newImageSet(martinWolf.ImageUrl), "The image set should be deterministic")
+	assert.Nil(t, newImageSet(""), "There should be no image set without an image URL")
+}
+
+func TestShouldReturnImageSetAndImageContents(t *testing.T) {
+	contents := imageContents(transformedMartinWolf)
+
+	assert.Equal(t, []imageContent{
+		{UUID: martinWolfImageSetUuid, Type: "ImageSet", Title: "Author headshot", Members: martinWolfImageSet.Members},
+		{UUID: martinWolfImageUuid, Type: "Image", Title: "Author headshot", BinaryUrl: martinWolf.ImageUrl},
+	}, contents, "There should be an image set and its image")
+	assert.Nil(t, imageContents(person{}), "There should be no images without an image set")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	jsonLDMediaType = "application/ld+json"
+	schemaContext   = "http://schema.org"
+	thingsBaseUrl   = "http://api.ft.com/things/"
+)
+
+// This struct reflects a schema.org Person as JSON-LD structured data
+type schemaPerson struct {
+	Context         string   `json:"@context"`
+	Type            string   `json:"@type"`
+	ID              string   `json:"@id"`
+	Name            string   `json:"name"`
+	HonorificPrefix string   `json:"honorificPrefix,omitempty"`
+	GivenName       string   `json:"givenName,omitempty"`
+	FamilyName      string   `json:"familyName,omitempty"`
+	AlternateName   []string `json:"alternateName,omitempty"`
+	JobTitle        string   `json:"jobTitle,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Image           string   `json:"image,omitempty"`
+	SameAs          []string `json:"sameAs,omitempty"`
+}
+
+func toSchemaPerson(p person) schemaPerson {
+	description := p.Description
+	if p.PlainDescription != "" {
+		description = p.PlainDescription
+	}
+
+	var sameAs []string
+	for _, profile := range []string{
+		profileUrl("https://twitter.com/", strings.TrimPrefix(p.TwitterHandle, "@")),
+		profileUrl("https://www.facebook.com/", p.FacebookProfile),
+		profileUrl("https://www.linkedin.com/in/", p.LinkedinProfile),
+	} {
+		if profile != "" {
+			sameAs = append(sameAs, profile)
+		}
+	}
+
+	return schemaPerson{
+		Context:         schemaContext,
+		Type:            "Person",
+		ID:              thingsBaseUrl + p.Uuid,
+		Name:            p.PrefLabel,
+		HonorificPrefix: p.Salutation,
+		GivenName:       p.GivenName,
+		FamilyName:      p.FamilyName,
+		AlternateName:   p.Aliases,
+		JobTitle:        p.Role,
+		Description:     strings.TrimSpace(description),
+		Image:           p.ImageUrl,
+		SameAs:          sameAs,
+	}
+}
+
+// profileUrl turns a social profile name into a URL, leaving the profiles that are already URLs as they are
+func profileUrl(baseUrl string, profile string) string {
+	profile = strings.TrimSpace(profile)
+	if profile == "" || strings.HasPrefix(profile, "http://") || strings.HasPrefix(profile, "https://") {
+		return profile
+	}
+	return baseUrl + profile
+}
+
+// prefersJSONLD tells whether an Accept header explicitly asks for JSON-LD with at least the quality of JSON,
+// which is also matched by the wildcards
+func prefersJSONLD(accept string) bool {
+	ld := mediaTypeQuality(accept, jsonLDMediaType, false)
+	return ld > 0 && ld >= mediaTypeQuality(accept, "application/json", true)
+}
+
+// mediaTypeQuality returns the q-value of a media type in an Accept header, taken from its most specific match,
+// or 0 when it does not match
+func mediaTypeQuality(accept string, mediaType string, wildcards bool) float64 {
+	mainType := strings.SplitN(mediaType, "/", 2)[0]
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		s := -1
+		switch {
+		case strings.EqualFold(name, mediaType):
+			s = 2
+		case wildcards && name == mainType+"/*":
+			s = 1
+		case wildcards && name == "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); strings.HasPrefix(param, "q=") && err == nil {
+				q = v
+			}
+		}
+		quality, specificity = q, s
+	}
+	return quality
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldConvertPersonToSchemaPerson(t *testing.T) {
+	p := aPerson
+	p.Salutation = "Dr"
+	p.Aliases = []string{"Cartman"}
+	p.Role = "Student"
+	p.LinkedinProfile = "https://www.linkedin.com/in/ProfessionalCartman"
+
+	sp := toSchemaPerson(p)
+
+	assert.Equal(t, schemaPerson{
+		Context:         "http://schema.org",
+		Type:            "Person",
+		ID:              "http://api.ft.com/things/" + cartmanUuid,
+		Name:            "Eric Cartman",
+		HonorificPrefix: "Dr",
+		GivenName:       "Eric",
+		FamilyName:      "Cartman",
+		AlternateName:   []string{"Cartman"},
+		JobTitle:        "Student",
+		Description:     aBio,
+		Image:           anAuthor.ImageUrl,
+		SameAs: []string{
+			"https://twitter.com/SouthPark",
+			"https://www.facebook.com/OfficialCartman",
+			"https://www.linkedin.com/in/ProfessionalCartman",
+		},
+	}, sp)
+}
+
+func TestShouldNegotiateJSONLD(t *testing.T) {
+	assert.True(t, prefersJSONLD("application/ld+json"))
+	assert.True(t, prefersJSONLD("text/html, Application/LD+JSON;q=0.5"))
+	assert.False(t, prefersJSONLD("application/ld+json;q=0"), "Zero quality should not be accepted")
+	assert.False(t, prefersJSONLD("application/json, */*"), "Wildcards should not select JSON-LD")
+	assert.False(t, prefersJSONLD(""))
+	assert.False(t, prefersJSONLD("application/json, application/ld+json;q=0.1"), "JSON should be returned when preferred")
+	assert.False(t, prefersJSONLD("application/ld+json;q=0.5, */*"), "JSON should be returned when the wildcard is preferred")
+	assert.False(t, prefersJSONLD("application/ld+json;q=0.5, application/*;q=0.8, */*;q=0.1"), "The most specific wildcard should count")
+	assert.True(t, prefersJSONLD("application/ld+json, application/json;q=0.9"))
+	assert.True(t, prefersJSONLD("application/ld+json, */*"), "JSON-LD should be returned when asked as much as any type")
+}
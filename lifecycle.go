@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// lifecycle tracks whether the service is shutting down, so that GTG fails while the in-flight requests finish
+type lifecycle struct {
+	stopping chan struct{}
+	mutex    sync.Mutex
+}
+
+func (l *lifecycle) startShutdown() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.stopping == nil {
+		l.stopping = make(chan struct{})
+	}
+	select {
+	case <-l.stopping:
+	default:
+		close(l.stopping)
+	}
+}
+
+func (l *lifecycle) isShuttingDown() bool {
+	select {
+	case <-l.shutdownStarted():
+		return true
+	default:
+		return false
+	}
+}
+
+// shutdownStarted returns a channel closed once the shutdown starts
+func (l *lifecycle) shutdownStarted() <-chan struct{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.stopping == nil {
+		l.stopping = make(chan struct{})
+	}
+	return l.stopping
+}
+
+// refreshUntilCached fetches the authors at startup in the background. The fetches that fail are retried, waiting
+// twice as long after each failure up to maxBackoff, until the authors are in cache or the shutdown starts.
+func refreshUntilCached(l *lifecycle, bs *berthaService, backoff time.Duration, maxBackoff time.Duration) {
+	for !l.isShuttingDown() {
+		err := bs.refreshCache(withTransactionID(context.Background(), newTransactionID()))
+		if _, partial := err.(*transformError); err == nil || partial {
+			return
+		}
+		log.Errorf("The first fetch of the authors failed, retrying in %v: %v", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-l.shutdownStarted():
+			return
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// shutdownConfig holds the options of the graceful shutdown. No snapshot is written when the snapshot file is empty.
+type shutdownConfig struct {
+	drainDelay   time.Duration
+	timeout      time.Duration
+	snapshotFile string
+}
+
+// shutdown stops the service gracefully: GTG fails during the drain delay so that the traffic is routed away,
+// then the server stops accepting connections and the in-flight requests and refresh are given until the timeout
+// to finish. The cached authors are written to the snapshot file last.
+func shutdown(server *http.Server, l *lifecycle, bs *berthaService, sc shutdownConfig) error {
+	l.startShutdown()
+	log.Infof("GTG is failing, waiting %v before closing the connections", sc.drainDelay)
+	time.Sleep(sc.drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sc.timeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != nil {
+		log.Warnf("Some requests were still in flight when the server stopped: %v", err)
+	}
+	if refreshErr := bs.waitForRefresh(ctx); refreshErr != nil {
+		log.Warnf("The refresh in flight was cancelled: %v", refreshErr)
+		err = refreshErr
+	}
+
+	if sc.snapshotFile != "" {
+		if snapshotErr := writeSnapshot(sc.snapshotFile, bs.getAllAuthors(context.Background())); snapshotErr != nil {
+			log.Errorf("Cannot write the snapshot of the authors: %v", snapshotErr)
+			return snapshotErr
+		}
+		log.Infof("Wrote the snapshot of the authors to %s", sc.snapshotFile)
+	}
+	return err
+}
+
+// snapshotAuthor is an author in the snapshot file. Unlike the v1 representation it keeps the localised descriptions,
+// so that the snapshot holds the same data as the cache.
+type snapshotAuthor struct {
+	person
+	LocalisedDescriptions map[string]localisedDescription `json:"localisedDescriptions,omitempty"`
+}
+
+// writeSnapshot writes the authors as a JSON array, replacing the file only once it is complete. The file holds
+// the personal data of the authors, so only the owner of the service can read it.
+func writeSnapshot(path string, authors []person) error {
+	snapshot := make([]snapshotAuthor, len(authors))
+	for i, a := range authors {
+		snapshot[i] = snapshotAuthor{person: a, LocalisedDescriptions: a.LocalisedDescriptions}
+	}
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
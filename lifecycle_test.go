@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoodToGoShouldFailWhenShuttingDown(t *testing.T) {
+	mbs := new(MockedBerthaService)
+	mbs.On("getConnectivity").Return(connectivityStatus{checkedAt: time.Now()})
+	mbs.On("getAuthorsCount").Return(2)
+	mbs.On("getRefreshStats").Return(refreshStats{lastSuccess: time.Now()})
+	ah := newAuthorHandler(mbs, nil, testHealthConfig)
+
+	ah.lifecycle.startShutdown()
+	w := httptest.NewRecorder()
+	ah.GoodToGo(w, httptest.NewRequest("GET", "/__gtg", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "GTG should fail once the shutdown started")
+}
+
+func TestGoodToGoShouldFailBeforeFirstRefresh(t *testing.T) {
+	bs := newEmptyBerthaService("http://localhost:1", &berthaTransformer{}, nil, 0, 0)
+	ah := newAuthorHandler(bs, nil, testHealthConfig)
+
+	w := httptest.NewRecorder()
+	ah.GoodToGo(w, httptest.NewRequest("GET", "/__gtg", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "GTG should fail until the authors are fetched")
+}
+
+func TestShutdownShouldFinishInFlightRequestsAndWriteSnapshot(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	bs, err := newBerthaService(berthaMock.URL+berthaPath, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+
+	requested := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requested)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	responded := make(chan int)
+	go func() {
+		resp, err := http.Get(server.URL)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		responded <- resp.StatusCode
+	}()
+	<-requested
+
+	dir, _ := ioutil.TempDir("", "snapshot")
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, "authors.json")
+	l := &lifecycle{}
+	err = shutdown(server.Config, l, bs, shutdownConfig{timeout: time.Second, snapshotFile: snapshot})
+
+	assert.Nil(t, err)
+	assert.True(t, l.isShuttingDown())
+	assert.Equal(t, http.StatusOK, <-responded, "The request in flight should be answered")
+
+	body, err := ioutil.ReadFile(snapshot)
+	assert.Nil(t, err)
+	var authors []snapshotAuthor
+	assert.Nil(t, json.Unmarshal(body, &authors))
+	assert.Len(t, authors, 2, "The snapshot should contain the cached authors")
+	cached := bs.getAllAuthors(context.Background())
+	assert.Equal(t, cached[1].Uuid, authors[1].Uuid, "The snapshot should contain the cached authors")
+	for i := range cached {
+		assert.Equal(t, cached[i].LocalisedDescriptions, authors[i].LocalisedDescriptions, "The localised descriptions should be kept")
+	}
+	info, err := os.Stat(snapshot)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Only the owner should read the personal data of the authors")
+}
+
+func TestShutdownShouldCancelRefreshAfterTimeout(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	hungBertha := startHangingBertha(cancelled)
+	defer hungBertha.Close()
+	bs, err := newBerthaService(hungBertha.URL, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+	go bs.refreshCache(context.Background())
+	time.Sleep(50 * time.Millisecond)
+
+	err = shutdown(&http.Server{}, &lifecycle{}, bs, shutdownConfig{timeout: 100 * time.Millisecond})
+
+	assert.Equal(t, context.DeadlineExceeded, err, "The refresh should not delay the shutdown past the timeout")
+	<-cancelled
+	assert.Equal(t, 2, bs.getAuthorsCount(context.Background()), "The cached authors should be kept")
+}
+
+func TestShouldRetryFirstRefreshUntilCached(t *testing.T) {
+	var calls int32
+	flakyBertha := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		berthaHandlerMock(w, r)
+	}))
+	defer flakyBertha.Close()
+	bs := newEmptyBerthaService(flakyBertha.URL, &berthaTransformer{}, nil, 0, 0)
+
+	refreshUntilCached(&lifecycle{}, bs, time.Millisecond, 2*time.Millisecond)
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "The failed fetches should be retried")
+	assert.Equal(t, 2, bs.getAuthorsCount(context.Background()), "The authors should be cached")
+}
+
+func TestShouldStopRetryingFirstRefreshOnShutdown(t *testing.T) {
+	bs := newEmptyBerthaService("http://localhost:1", &berthaTransformer{}, nil, 0, 0)
+	l := &lifecycle{}
+	stopped := make(chan struct{})
+	go func() {
+		refreshUntilCached(l, bs, time.Hour, time.Hour)
+		close(stopped)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	l.startShutdown()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("The wait before the next retry should stop once the shutdown started")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type languagePreference struct {
+	tag     string
+	quality float64
+}
+
+type byQuality []languagePreference
+
+func (p byQuality) Len() int           { return len(p) }
+func (p byQuality) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byQuality) Less(i, j int) bool { return p[i].quality > p[j].quality }
+
+// acceptedLanguages returns the lower case language tags of an Accept-Language header, most preferred first
+func acceptedLanguages(acceptLanguage string) []string {
+	var prefs []languagePreference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			prefs = append(prefs, languagePreference{tag: tag, quality: quality})
+		}
+	}
+
+	sort.Stable(byQuality(prefs))
+
+	tags := make([]string, len(prefs))
+	for i, p := range prefs {
+		tags[i] = p.tag
+	}
+	return tags
+}
+
+// languageTag turns the suffix of a Bertha column such as zh_Hans into a lower case BCP 47 language tag, zh-hans
+func languageTag(suffix string) string {
+	return strings.ToLower(strings.Replace(suffix, "_", "-", -1))
+}
+
+// defaultLanguage is the language of the biography column of Bertha
+const defaultLanguage = "en"
+
+// localise replaces the description of the person with the one in the most preferred available language.
+// A language tag such as zh-cn falls back to its primary language zh, and the default language is available
+// for every person. When none of the accepted languages is available the person is returned as it is,
+// together with an empty language.
+func localise(p person, acceptLanguage string) (person, string) {
+	if len(p.LocalisedDescriptions) == 0 {
+		return p, ""
+	}
+	for _, tag := range acceptedLanguages(acceptLanguage) {
+		for _, candidate := range []string{tag, strings.SplitN(tag, "-", 2)[0]} {
+			if d, found := p.LocalisedDescriptions[candidate]; found {
+				p.Description = d.Description
+				p.DescriptionXML = d.DescriptionXML
+				p.PlainDescription = d.PlainDescription
+				p.Summary = d.Summary
+				return p, candidate
+			}
+			if candidate == defaultLanguage {
+				return p, defaultLanguage
+			}
+		}
+	}
+	return p, ""
+}
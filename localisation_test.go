@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var lucyKellawayZh = localisedDescription{
+	Description:    "露西·凯拉韦是英国《金融时报》副主编和管理专栏作家。",
+	DescriptionXML: "<p>露西·凯拉韦是英国《金融时报》副主编和管理专栏作家。</p>",
+}
+
+func TestShouldParseAcceptLanguageByQuality(t *testing.T) {
+	assert.Equal(t, []string{"ja", "zh-cn", "en"}, acceptedLanguages("en;q=0.5, zh-CN;q=0.8, ja, *;q=0.1"), "Languages should be ordered by quality")
+	assert.Equal(t, []string{"en"}, acceptedLanguages("en, fr;q=0"), "Languages with zero quality should be ignored")
+	assert.Empty(t, acceptedLanguages(""), "There should be no languages")
+}
+
+func TestShouldLocaliseDescription(t *testing.T) {
+	p := person{
+		Description:           "Lucy Kellaway is an Associate Editor.",
+		LocalisedDescriptions: map[string]localisedDescription{"zh": lucyKellawayZh},
+	}
+
+	localised, lang := localise(p, "zh-CN, en;q=0.8")
+	assert.Equal(t, "zh", lang, "The primary language should match")
+	assert.Equal(t, lucyKellawayZh.Description, localised.Description)
+	assert.Equal(t, lucyKellawayZh.DescriptionXML, localised.DescriptionXML)
+
+	localised, lang = localise(p, "en-GB,en;q=0.9,zh;q=0.1")
+	assert.Equal(t, "en", lang, "The default language should match before the less preferred ones")
+	assert.Equal(t, p, localised, "The default description should be kept")
+
+	localised, lang = localise(p, "fr, ja")
+	assert.Equal(t, "", lang, "No language should match")
+	assert.Equal(t, p, localised, "The default description should be kept")
+}
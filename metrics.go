@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+const metricsPath = "/metrics"
+
+const prometheusMediaType = "text/plain; version=0.0.4"
+
+var (
+	refreshDuration     = metrics.NewRegisteredTimer("curated_authors_refresh_duration_seconds", metrics.DefaultRegistry)
+	refreshSuccesses    = metrics.NewRegisteredCounter("curated_authors_refresh_success_total", metrics.DefaultRegistry)
+	refreshFailures     = metrics.NewRegisteredCounter("curated_authors_refresh_failure_total", metrics.DefaultRegistry)
+	berthaDuration      = metrics.NewRegisteredTimer("curated_authors_bertha_request_duration_seconds", metrics.DefaultRegistry)
+	transformErrors     = metrics.NewRegisteredCounter("curated_authors_transform_errors_total", metrics.DefaultRegistry)
+	authorsInCache      = metrics.NewRegisteredGauge("curated_authors_authors_in_cache", metrics.DefaultRegistry)
+	lastRefreshUnixTime = metrics.NewRegisteredGauge("curated_authors_last_successful_refresh_timestamp_seconds", metrics.DefaultRegistry)
+	cacheAge            = metrics.NewRegisteredFunctionalGaugeFloat64("curated_authors_cache_age_seconds", metrics.DefaultRegistry, func() float64 {
+		last := lastRefreshUnixTime.Value()
+		if last == 0 {
+			return 0
+		}
+		return time.Since(time.Unix(last, 0)).Seconds()
+	})
+)
+
+// countBerthaResponse counts the responses of the HTTP cache to the calls to Bertha by status. The responses served
+// by the cache, fresh or revalidated by Bertha, are counted apart.
+func countBerthaResponse(resp *http.Response) {
+	name := fmt.Sprintf(`curated_authors_bertha_responses_total{status="%d",from_cache="%t"}`, resp.StatusCode, resp.Header.Get("X-From-Cache") == "1")
+	metrics.GetOrRegisterCounter(name, metrics.DefaultRegistry).Inc(1)
+}
+
+// upstreamCounter counts the responses actually sent by Bertha by status. It sits under the HTTP cache,
+// which turns a 304 of Bertha into the cached 200, so that the ratio of 304 to 200 responses can be measured.
+type upstreamCounter struct {
+	next http.RoundTripper
+}
+
+func (u *upstreamCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := u.next.RoundTrip(req)
+	if err == nil {
+		name := fmt.Sprintf(`curated_authors_bertha_upstream_responses_total{status="%d"}`, resp.StatusCode)
+		metrics.GetOrRegisterCounter(name, metrics.DefaultRegistry).Inc(1)
+	}
+	return resp, err
+}
+
+// Characters not allowed in Prometheus metric names
+var invalidMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+var summaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// prometheusHandler exposes the metrics of a registry in the Prometheus text format.
+// Labels are part of the metric names, e.g. `requests_total{status="200"}`, timers are summaries in seconds.
+func prometheusHandler(r metrics.Registry) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		families := map[string][]string{}
+		types := map[string]string{}
+		r.Each(func(name string, i interface{}) {
+			family, labels := splitMetricName(name)
+			var samples []string
+			switch m := i.(type) {
+			case metrics.Counter:
+				types[family] = "counter"
+				samples = []string{sample(family, labels, float64(m.Count()))}
+			case metrics.Gauge:
+				types[family] = "gauge"
+				samples = []string{sample(family, labels, float64(m.Value()))}
+			case metrics.GaugeFloat64:
+				types[family] = "gauge"
+				samples = []string{sample(family, labels, m.Value())}
+			case metrics.Meter:
+				types[family] = "counter"
+				samples = []string{sample(family, labels, float64(m.Count()))}
+			case metrics.Timer:
+				types[family] = "summary"
+				s := m.Snapshot()
+				samples = summarySamples(family, labels, s.Percentiles(summaryQuantiles), float64(s.Sum()), s.Count(), 1/float64(time.Second))
+			case metrics.Histogram:
+				types[family] = "summary"
+				s := m.Snapshot()
+				samples = summarySamples(family, labels, s.Percentiles(summaryQuantiles), float64(s.Sum()), s.Count(), 1)
+			default:
+				return
+			}
+			families[family] = append(families[family], samples...)
+		})
+
+		names := make([]string, 0, len(families))
+		for family := range families {
+			names = append(names, family)
+		}
+		sort.Strings(names)
+
+		var buf bytes.Buffer
+		for _, family := range names {
+			samples := families[family]
+			sort.Strings(samples)
+			fmt.Fprintf(&buf, "# TYPE %s %s\n", family, types[family])
+			for _, s := range samples {
+				buf.WriteString(s + "\n")
+			}
+		}
+		writer.Header().Set("Content-Type", prometheusMediaType)
+		buf.WriteTo(writer)
+	}
+}
+
+// splitMetricName separates the labels from the name of a metric, replacing the characters Prometheus does not allow in the name
+func splitMetricName(name string) (string, string) {
+	labels := ""
+	if i := strings.Index(name, "{"); i >= 0 && strings.HasSuffix(name, "}") {
+		name, labels = name[:i], name[i+1:len(name)-1]
+	}
+	return invalidMetricNameChars.ReplaceAllString(strings.TrimSpace(name), "_"), labels
+}
+
+func sample(name string, labels string, value float64) string {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	return name + " " + strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// summarySamples converts the quantiles and the sum of a summary with a scale, e.g. from nanoseconds to seconds
+func summarySamples(name string, labels string, quantiles []float64, sum float64, count int64, scale float64) []string {
+	var samples []string
+	for i, q := range summaryQuantiles {
+		quantileLabels := fmt.Sprintf(`quantile="%g"`, q)
+		if labels != "" {
+			quantileLabels = labels + "," + quantileLabels
+		}
+		samples = append(samples, sample(name, quantileLabels, quantiles[i]*scale))
+	}
+	return append(samples,
+		sample(name+"_sum", labels, sum*scale),
+		sample(name+"_count", labels, float64(count)))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldExposeMetricsInPrometheusFormat(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.NewRegisteredCounter(`responses_total{status="200"}`, r).Inc(3)
+	metrics.NewRegisteredCounter(`responses_total{status="304"}`, r).Inc(1)
+	metrics.NewRegisteredGauge("authors", r).Update(2)
+	metrics.NewRegisteredTimer("GET /transformers/authors", r).Update(2 * time.Second)
+
+	w := httptest.NewRecorder()
+	prometheusHandler(r)(w, httptest.NewRequest("GET", metricsPath, nil))
+
+	expected := `# TYPE GET__transformers_authors summary
+GET__transformers_authors_count 1
+GET__transformers_authors_sum 2
+GET__transformers_authors{quantile="0.5"} 2
+GET__transformers_authors{quantile="0.9"} 2
+GET__transformers_authors{quantile="0.99"} 2
+# TYPE authors gauge
+authors 2
+# TYPE responses_total counter
+responses_total{status="200"} 3
+responses_total{status="304"} 1
+`
+	assert.Equal(t, prometheusMediaType, w.Header().Get("Content-Type"), "Unexpected content type")
+	assert.Equal(t, expected, w.Body.String(), "Unexpected metrics")
+}
+
+func TestShouldMeasureRefreshes(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	successes := refreshSuccesses.Count()
+	fetches := berthaDuration.Count()
+
+	_, err := newBerthaService(berthaMock.URL+berthaPath, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+
+	assert.Equal(t, successes+1, refreshSuccesses.Count(), "The refresh should be counted")
+	assert.Equal(t, fetches+1, berthaDuration.Count(), "The call to Bertha should be timed")
+	assert.Equal(t, int64(2), authorsInCache.Value(), "The authors in cache should be counted")
+
+	w := httptest.NewRecorder()
+	prometheusHandler(metrics.DefaultRegistry)(w, httptest.NewRequest("GET", metricsPath, nil))
+	body := w.Body.String()
+	for _, name := range []string{
+		"curated_authors_refresh_duration_seconds_count",
+		"curated_authors_refresh_failure_total",
+		`curated_authors_bertha_responses_total{status="200",from_cache="false"}`,
+		"curated_authors_transform_errors_total",
+		"curated_authors_cache_age_seconds",
+	} {
+		assert.True(t, strings.Contains(body, "\n"+name+" "), "Metric "+name+" should be exposed")
+	}
+}
+
+func TestShouldCountRevalidationsOfBertha(t *testing.T) {
+	startBerthaMock("happy")
+	defer berthaMock.Close()
+	fetched := metrics.GetOrRegisterCounter(`curated_authors_bertha_upstream_responses_total{status="200"}`, metrics.DefaultRegistry)
+	revalidated := metrics.GetOrRegisterCounter(`curated_authors_bertha_upstream_responses_total{status="304"}`, metrics.DefaultRegistry)
+	fetches, revalidations := fetched.Count(), revalidated.Count()
+
+	bs, err := newBerthaService(berthaMock.URL+berthaPath, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, bs.refreshCache(context.Background()))
+
+	assert.Equal(t, fetches+1, fetched.Count(), "The first call should be answered with the authors")
+	assert.Equal(t, revalidations+1, revalidated.Count(), "The second call should be revalidated with a 304")
+}
+
+func TestShouldServeMetrics(t *testing.T) {
+	server := httptest.NewServer(setupServiceHandlers(newAuthorHandler(new(MockedBerthaService), nil, healthConfig{}), false, nil))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + metricsPath)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Contains(t, getStringFromReader(resp.Body), "# TYPE curated_authors_refresh_success_total counter", "Refreshes should be counted")
+}
@@ -0,0 +1,109 @@
+package main
+
+import "strings"
+
+// Titles recognised at the start of an author name, keyed by their lower case form without dots
+var salutations = map[string]string{
+	"sir":       "Sir",
+	"dame":      "Dame",
+	"lord":      "Lord",
+	"lady":      "Lady",
+	"baron":     "Baron",
+	"baroness":  "Baroness",
+	"dr":        "Dr",
+	"prof":      "Prof",
+	"professor": "Professor",
+	"rev":       "Rev",
+	"mr":        "Mr",
+	"mrs":       "Mrs",
+	"ms":        "Ms",
+	"miss":      "Miss",
+}
+
+// Lower case particles that belong to the family name, e.g. "van" in "Ludwig van Beethoven"
+var familyNameParticles = map[string]bool{
+	"van":   true,
+	"von":   true,
+	"der":   true,
+	"den":   true,
+	"de":    true,
+	"del":   true,
+	"della": true,
+	"da":    true,
+	"di":    true,
+	"du":    true,
+	"la":    true,
+	"le":    true,
+	"bin":   true,
+	"al":    true,
+}
+
+// Generational suffixes that are not part of the family name
+var nameSuffixes = map[string]bool{
+	"jr":  true,
+	"sr":  true,
+	"ii":  true,
+	"iii": true,
+	"iv":  true,
+}
+
+type personName struct {
+	salutation string
+	givenName  string
+	familyName string
+	suffix     string
+}
+
+func parseName(name string) personName {
+	tokens := strings.Fields(name)
+	pn := personName{}
+
+	if len(tokens) > 1 {
+		if s, found := salutations[normaliseNameToken(tokens[0])]; found {
+			pn.salutation = s
+			tokens = tokens[1:]
+		}
+	}
+
+	if len(tokens) > 1 && nameSuffixes[normaliseNameToken(tokens[len(tokens)-1])] {
+		pn.suffix = tokens[len(tokens)-1]
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	if len(tokens) == 0 {
+		return pn
+	}
+
+	familyStart := len(tokens) - 1
+	for familyStart > 1 && familyNameParticles[tokens[familyStart-1]] {
+		familyStart--
+	}
+
+	pn.givenName = strings.Join(tokens[:familyStart], " ")
+	pn.familyName = strings.Join(tokens[familyStart:], " ")
+	return pn
+}
+
+// fullName returns the name without the salutation
+func (pn personName) fullName() string {
+	return joinNonEmpty(" ", pn.givenName, pn.familyName, pn.suffix)
+}
+
+// sortKey orders authors by family name first, e.g. "Wolf, Martin"
+func (pn personName) sortKey() string {
+	return joinNonEmpty(", ", pn.familyName, joinNonEmpty(" ", pn.givenName, pn.suffix))
+}
+
+func normaliseNameToken(token string) string {
+	return strings.ToLower(strings.TrimSuffix(token, "."))
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	nonEmpty := []string{}
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldParseNames(t *testing.T) {
+	var tests = []struct {
+		name     string
+		expected personName
+		sortKey  string
+	}{
+		{"Martin Wolf", personName{givenName: "Martin", familyName: "Wolf"}, "Wolf, Martin"},
+		{"Sir Martin Wolf", personName{salutation: "Sir", givenName: "Martin", familyName: "Wolf"}, "Wolf, Martin"},
+		{"Dr. Lucy Kellaway", personName{salutation: "Dr", givenName: "Lucy", familyName: "Kellaway"}, "Kellaway, Lucy"},
+		{"Lord John Maynard Keynes", personName{salutation: "Lord", givenName: "John Maynard", familyName: "Keynes"}, "Keynes, John Maynard"},
+		{"Ludwig van Beethoven", personName{givenName: "Ludwig", familyName: "van Beethoven"}, "van Beethoven, Ludwig"},
+		{"Martin Luther King Jr.", personName{givenName: "Martin Luther", familyName: "King", suffix: "Jr."}, "King, Martin Luther Jr."},
+		{"Lex", personName{familyName: "Lex"}, "Lex"},
+		{"Sir", personName{familyName: "Sir"}, "Sir"},
+		{"", personName{}, ""},
+	}
+
+	for _, test := range tests {
+		pn := parseName(test.name)
+		assert.Equal(t, test.expected, pn, "Unexpected parsing of "+test.name)
+		assert.Equal(t, test.sortKey, pn.sortKey(), "Unexpected sort key for "+test.name)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"net/http"
+
+	status "github.com/Financial-Times/service-status-go/httphandlers"
+)
+
+const apiPath = "/__api"
+
+// This struct reflects the subset of the OpenAPI 3 document model used to describe the service
+type openAPIDocument struct {
+	OpenAPI    string                             `json:"openapi"`
+	Info       apiInfo                            `json:"info"`
+	Paths      map[string]map[string]apiOperation `json:"paths"`
+	Components apiComponents                      `json:"components"`
+}
+
+type apiInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type apiComponents struct {
+	Schemas map[string]*apiSchema `json:"schemas"`
+}
+
+type apiOperation struct {
+	Summary    string                 `json:"summary"`
+	Parameters []apiParameter         `json:"parameters,omitempty"`
+	Responses  map[string]apiResponse `json:"responses"`
+}
+
+type apiParameter struct {
+	Name     string     `json:"name"`
+	In       string     `json:"in"`
+	Required bool       `json:"required,omitempty"`
+	Schema   *apiSchema `json:"schema"`
+}
+
+type apiResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]apiMediaType `json:"content,omitempty"`
+}
+
+type apiMediaType struct {
+	Schema *apiSchema `json:"schema"`
+}
+
+type apiSchema struct {
+	Ref                  string                `json:"$ref,omitempty"`
+	Type                 string                `json:"type,omitempty"`
+	Nullable             bool                  `json:"nullable,omitempty"`
+	Properties           map[string]*apiSchema `json:"properties,omitempty"`
+	Required             []string              `json:"required,omitempty"`
+	AdditionalProperties *bool                 `json:"additionalProperties,omitempty"`
+	Items                *apiSchema            `json:"items,omitempty"`
+}
+
+var noAdditionalProperties = false
+
+func stringSchema() *apiSchema {
+	return &apiSchema{Type: "string"}
+}
+
+func integerSchema() *apiSchema {
+	return &apiSchema{Type: "integer"}
+}
+
+func booleanSchema() *apiSchema {
+	return &apiSchema{Type: "boolean"}
+}
+
+func arraySchema(items *apiSchema) *apiSchema {
+	return &apiSchema{Type: "array", Items: items}
+}
+
+func refSchema(name string) *apiSchema {
+	return &apiSchema{Ref: "#/components/schemas/" + name}
+}
+
+// objectSchema describes an object that may not have any property other than the given ones
+func objectSchema(properties map[string]*apiSchema, required ...string) *apiSchema {
+	return &apiSchema{Type: "object", Properties: properties, Required: required, AdditionalProperties: &noAdditionalProperties}
+}
+
+func apiSchemas() map[string]*apiSchema {
+	uuids := arraySchema(stringSchema())
+	uuids.Nullable = true
+
+	return map[string]*apiSchema{
+		"Message": objectSchema(map[string]*apiSchema{
+			"message": stringSchema(),
+		}, "message"),
+		"Error": objectSchema(map[string]*apiSchema{
+			"code":          stringSchema(),
+			"message":       stringSchema(),
+			"transactionId": stringSchema(),
+			"details":       {Type: "object"},
+		}, "code", "message"),
+		"AlternativeIdentifiers": objectSchema(map[string]*apiSchema{
+			"TME":   arraySchema(stringSchema()),
+			"uuids": uuids,
+		}, "uuids"),
+		"ImageSet": objectSchema(map[string]*apiSchema{
+			"uuid":    stringSchema(),
+			"members": arraySchema(refSchema("ImageMember")),
+		}, "uuid", "members"),
+		"ImageMember": objectSchema(map[string]*apiSchema{
+			"uuid":      stringSchema(),
+			"binaryUrl": stringSchema(),
+		}, "uuid", "binaryUrl"),
+		"Person": objectSchema(map[string]*apiSchema{
+			"uuid":                   stringSchema(),
+			"birthYear":              integerSchema(),
+			"alternativeIdentifiers": refSchema("AlternativeIdentifiers"),
+			"name":                   stringSchema(),
+			"prefLabel":              stringSchema(),
+			"salutation":             stringSchema(),
+			"givenName":              stringSchema(),
+			"familyName":             stringSchema(),
+			"sortKey":                stringSchema(),
+			"aliases":                arraySchema(stringSchema()),
+			"role":                   stringSchema(),
+			"emailAddress":           stringSchema(),
+			"twitterHandle":          stringSchema(),
+			"facebookProfile":        stringSchema(),
+			"linkedinProfile":        stringSchema(),
+			"description":            stringSchema(),
+			"descriptionXML":         stringSchema(),
+			"plainDescription":       stringSchema(),
+			"summary":                stringSchema(),
+			"_imageUrl":              stringSchema(),
+			"imageSet":               refSchema("ImageSet"),
+		}, "uuid", "alternativeIdentifiers", "prefLabel"),
+		"PersonV2": objectSchema(map[string]*apiSchema{
+			"uuid": stringSchema(),
+			"identifiers": objectSchema(map[string]*apiSchema{
+				"uuids": arraySchema(stringSchema()),
+				"tme":   arraySchema(stringSchema()),
+			}),
+			"prefLabel":        stringSchema(),
+			"birthYear":        integerSchema(),
+			"salutation":       stringSchema(),
+			"givenName":        stringSchema(),
+			"familyName":       stringSchema(),
+			"sortKey":          stringSchema(),
+			"aliases":          arraySchema(stringSchema()),
+			"role":             stringSchema(),
+			"emailAddress":     stringSchema(),
+			"twitterHandle":    stringSchema(),
+			"facebookProfile":  stringSchema(),
+			"linkedinProfile":  stringSchema(),
+			"description":      stringSchema(),
+			"descriptionXML":   stringSchema(),
+			"plainDescription": stringSchema(),
+			"summary":          stringSchema(),
+			"imageSet":         refSchema("ImageSet"),
+		}, "uuid", "identifiers", "prefLabel"),
+		"SchemaPerson": objectSchema(map[string]*apiSchema{
+			"@context":        stringSchema(),
+			"@type":           stringSchema(),
+			"@id":             stringSchema(),
+			"name":            stringSchema(),
+			"honorificPrefix": stringSchema(),
+			"givenName":       stringSchema(),
+			"familyName":      stringSchema(),
+			"alternateName":   arraySchema(stringSchema()),
+			"jobTitle":        stringSchema(),
+			"description":     stringSchema(),
+			"image":           stringSchema(),
+			"sameAs":          arraySchema(stringSchema()),
+		}, "@context", "@type", "@id", "name"),
+		"ImageContent": objectSchema(map[string]*apiSchema{
+			"uuid":      stringSchema(),
+			"type":      stringSchema(),
+			"title":     stringSchema(),
+			"binaryUrl": stringSchema(),
+			"members":   arraySchema(refSchema("ImageMember")),
+		}, "uuid", "type", "title"),
+		"AuditEntry": objectSchema(map[string]*apiSchema{
+			"time":           stringSchema(),
+			"transactionId":  stringSchema(),
+			"caller":         stringSchema(),
+			"upstreamStatus": integerSchema(),
+			"etag":           stringSchema(),
+			"fromCache":      booleanSchema(),
+			"authorsBefore":  integerSchema(),
+			"authorsAfter":   integerSchema(),
+			"diff":           refSchema("AuditDiff"),
+			"durationMillis": integerSchema(),
+			"error":          stringSchema(),
+		}, "time", "authorsBefore", "authorsAfter", "diff", "durationMillis"),
+		"AuditDiff": objectSchema(map[string]*apiSchema{
+			"added":   integerSchema(),
+			"changed": integerSchema(),
+			"removed": integerSchema(),
+			"removedAuthors": arraySchema(objectSchema(map[string]*apiSchema{
+				"uuid":      stringSchema(),
+				"prefLabel": stringSchema(),
+			}, "uuid", "prefLabel")),
+		}, "added", "changed", "removed"),
+		"ImageStatus": objectSchema(map[string]*apiSchema{
+			"authorUuid":    stringSchema(),
+			"imageUrl":      stringSchema(),
+			"statusCode":    integerSchema(),
+			"contentType":   stringSchema(),
+			"contentLength": integerSchema(),
+			"error":         stringSchema(),
+			"broken":        booleanSchema(),
+		}, "authorUuid", "imageUrl", "broken"),
+	}
+}
+
+func content(mediaType string, schema *apiSchema) map[string]apiMediaType {
+	return map[string]apiMediaType{mediaType: {Schema: schema}}
+}
+
+func jsonResponse(description string, schema *apiSchema) apiResponse {
+	return apiResponse{Description: description, Content: content("application/json", schema)}
+}
+
+func textResponse(description string, mediaType string) apiResponse {
+	return apiResponse{Description: description, Content: content(mediaType, stringSchema())}
+}
+
+var errorResponse = jsonResponse("Error with one of the codes NOT_FOUND, INVALID_UUID, INVALID_PARAMETER, UNAUTHORIZED, REFRESH_THROTTLED, UPSTREAM_FAILURE, TRANSFORM_FAILURE or INTERNAL_ERROR", refSchema("Error"))
+
+var uuidParameter = apiParameter{Name: "uuid", In: "path", Required: true, Schema: stringSchema()}
+
+// newOpenAPIDocument describes the routes registered by setupServiceHandlers
+func newOpenAPIDocument(publishImages bool) openAPIDocument {
+	paths := map[string]map[string]apiOperation{
+		status.PingPath:        {"get": {Summary: "Ping", Responses: map[string]apiResponse{"200": textResponse("pong", "text/plain")}}},
+		status.PingPathDW:      {"get": {Summary: "Ping", Responses: map[string]apiResponse{"200": textResponse("pong", "text/plain")}}},
+		status.BuildInfoPath:   {"get": {Summary: "Build information", Responses: map[string]apiResponse{"200": {Description: "Build information"}}}},
+		status.BuildInfoPathDW: {"get": {Summary: "Build information", Responses: map[string]apiResponse{"200": {Description: "Build information"}}}},
+		"/__health":            {"get": {Summary: "Health checks", Responses: map[string]apiResponse{"200": {Description: "FT health check results"}}}},
+		status.GTGPath: {"get": {Summary: "Good to go", Responses: map[string]apiResponse{
+			"200": {Description: "The service can serve requests"},
+			"503": {Description: "The service cannot serve requests"},
+		}}},
+		metricsPath: {"get": {Summary: "Metrics in the Prometheus text format", Responses: map[string]apiResponse{"200": textResponse("Prometheus metrics", "text/plain")}}},
+		apiPath:     {"get": {Summary: "This OpenAPI document", Responses: map[string]apiResponse{"200": jsonResponse("OpenAPI document", &apiSchema{Type: "object"})}}},
+	}
+
+	addAuthorPaths(paths, "", "Person", publishImages)
+	addAuthorPaths(paths, "/v1", "Person", publishImages)
+	addAuthorPaths(paths, "/v2", "PersonV2", publishImages)
+
+	return openAPIDocument{
+		OpenAPI: "3.0.0",
+		Info: apiInfo{
+			Title:       "Curated Authors Transformer",
+			Description: "A RESTful API for transforming Bertha Curated Authors to UP People JSON",
+			Version:     "2",
+		},
+		Paths:      paths,
+		Components: apiComponents{Schemas: apiSchemas()},
+	}
+}
+
+func addAuthorPaths(paths map[string]map[string]apiOperation, versionPrefix string, personSchema string, publishImages bool) {
+	base := versionPrefix + "/transformers/authors"
+	paths[base] = map[string]apiOperation{"post": {
+		Summary: "Refresh the authors from Bertha",
+		Responses: map[string]apiResponse{
+			"200": jsonResponse("Authors fetched", refSchema("Message")),
+			"401": errorResponse,
+			"429": errorResponse,
+			"500": errorResponse,
+		},
+	}}
+	paths[base+"/__count"] = map[string]apiOperation{"get": {
+		Summary: "Refresh the authors and count them, refreshing only for authenticated callers when authentication is enabled",
+		Responses: map[string]apiResponse{
+			"200": textResponse("Number of authors", "text/plain"),
+			"500": errorResponse,
+		},
+	}}
+	paths[base+"/__ids"] = map[string]apiOperation{"get": {
+		Summary:   "UUIDs of the authors, as a sequence of {\"id\":\"...\"} objects",
+		Responses: map[string]apiResponse{"200": textResponse("Author UUIDs", "text/plain")},
+	}}
+	paths[base+"/__images"] = map[string]apiOperation{"get": {
+		Summary:    "Outcome of the last verification of the author images",
+		Parameters: []apiParameter{{Name: "broken", In: "query", Schema: booleanSchema()}},
+		Responses: map[string]apiResponse{
+			"200": jsonResponse("Image statuses", arraySchema(refSchema("ImageStatus"))),
+			"401": errorResponse,
+		},
+	}}
+	paths[base+"/__audit"] = map[string]apiOperation{"get": {
+		Summary:    "The last refreshes of the authors, oldest first",
+		Parameters: []apiParameter{{Name: "limit", In: "query", Schema: integerSchema()}},
+		Responses: map[string]apiResponse{
+			"200": jsonResponse("Audited refreshes", arraySchema(refSchema("AuditEntry"))),
+			"400": errorResponse,
+			"401": errorResponse,
+		},
+	}}
+	paths[base+"/__export.ttl"] = map[string]apiOperation{"get": {
+		Summary:   "All the authors as RDF Turtle",
+		Responses: map[string]apiResponse{"200": textResponse("Turtle", turtleMediaType)},
+	}}
+	paths[base+"/__export.nt"] = map[string]apiOperation{"get": {
+		Summary:   "All the authors as RDF N-Triples",
+		Responses: map[string]apiResponse{"200": textResponse("N-Triples", nTriplesMediaType)},
+	}}
+	paths[base+"/__export.csv"] = map[string]apiOperation{"get": {
+		Summary: "All the authors as CSV",
+		Responses: map[string]apiResponse{
+			"200": textResponse("CSV", csvMediaType),
+			"500": errorResponse,
+		},
+	}}
+	authorContent := content("application/json", refSchema(personSchema))
+	authorContent[jsonLDMediaType] = apiMediaType{Schema: refSchema("SchemaPerson")}
+	paths[base+"/{uuid}"] = map[string]apiOperation{"get": {
+		Summary:    "An author, localised by Accept-Language and as schema.org JSON-LD with Accept: application/ld+json",
+		Parameters: []apiParameter{uuidParameter},
+		Responses: map[string]apiResponse{
+			"200": {Description: "Author", Content: authorContent},
+			"301": {Description: "Moved to the canonical UUID of an author found by an alternative UUID"},
+			"400": errorResponse,
+			"404": errorResponse,
+		},
+	}}
+
+	if publishImages {
+		images := versionPrefix + "/transformers/author-images"
+		paths[images+"/__ids"] = map[string]apiOperation{"get": {
+			Summary:   "UUIDs of the author image sets and images, as a sequence of {\"id\":\"...\"} objects",
+			Responses: map[string]apiResponse{"200": textResponse("Image UUIDs", "text/plain")},
+		}}
+		paths[images+"/{uuid}"] = map[string]apiOperation{"get": {
+			Summary:    "Metadata of an author image set or image",
+			Parameters: []apiParameter{uuidParameter},
+			Responses: map[string]apiResponse{
+				"200": jsonResponse("Image set or image", refSchema("ImageContent")),
+				"404": errorResponse,
+			},
+		}}
+	}
+}
+
+func apiHandler(publishImages bool) http.HandlerFunc {
+	doc := newOpenAPIDocument(publishImages)
+	return func(writer http.ResponseWriter, req *http.Request) {
+		writeJSONResponse(doc, writer, req)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// validateSchema returns the differences between a decoded JSON value and a schema of the document
+func validateSchema(doc openAPIDocument, s *apiSchema, value interface{}, path string) []string {
+	if s.Ref != "" {
+		return validateSchema(doc, doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")], value, path)
+	}
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return []string{path + " should not be null"}
+	}
+
+	var errs []string
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{path + " should be an object"}
+		}
+		for _, r := range s.Required {
+			if _, found := obj[r]; !found {
+				errs = append(errs, fmt.Sprintf("%s.%s is required", path, r))
+			}
+		}
+		for k, v := range obj {
+			ps, found := s.Properties[k]
+			if !found {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					errs = append(errs, fmt.Sprintf("%s.%s is not in the specification", path, k))
+				}
+				continue
+			}
+			errs = append(errs, validateSchema(doc, ps, v, path+"."+k)...)
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return []string{path + " should be an array"}
+		}
+		for i, v := range arr {
+			errs = append(errs, validateSchema(doc, s.Items, v, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			errs = append(errs, path+" should be a string")
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			errs = append(errs, path+" should be an integer")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, path+" should be a boolean")
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+// assertMatchesSpec checks that the status, content type and body of a response are described by the specification
+func assertMatchesSpec(t *testing.T, doc openAPIDocument, pathTemplate string, method string, resp *http.Response) {
+	op, found := doc.Paths[pathTemplate][strings.ToLower(method)]
+	if !assert.True(t, found, "%s %s should be in the specification", method, pathTemplate) {
+		return
+	}
+	r, found := op.Responses[fmt.Sprintf("%d", resp.StatusCode)]
+	if !assert.True(t, found, "%s %s should describe the %d response", method, pathTemplate, resp.StatusCode) || r.Content == nil {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	mt, found := r.Content[mediaType]
+	if !assert.True(t, found, "%s %s should describe %s responses", method, pathTemplate, mediaType) {
+		return
+	}
+	if !strings.HasSuffix(mediaType, "json") {
+		return
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	var value interface{}
+	assert.Nil(t, json.Unmarshal(body, &value), "The response of %s %s should be JSON", method, pathTemplate)
+	assert.Empty(t, validateSchema(doc, mt.Schema, value, "$"), "The response of %s %s should match the specification", method, pathTemplate)
+}
+
+func TestShouldDescribeEveryRoute(t *testing.T) {
+	doc := newOpenAPIDocument(true)
+	r := setupServiceHandlers(newAuthorHandler(new(MockedBerthaService), nil, healthConfig{}), true, nil).(*mux.Router)
+
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"}
+		}
+		for _, m := range methods {
+			_, found := doc.Paths[path][strings.ToLower(m)]
+			assert.True(t, found, "%s %s should be in the specification", m, path)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestShouldServeSpecification(t *testing.T) {
+	startCuratedAuthorsTransformer(new(MockedBerthaService))
+	defer curatedAuthorsTransformer.Close()
+
+	resp, err := http.Get(curatedAuthorsTransformer.URL + "/__api")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	var doc openAPIDocument
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200")
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Equal(t, "3.0.0", doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/transformers/authors/{uuid}")
+}
+
+func TestResponsesShouldMatchSpecification(t *testing.T) {
+	doc := newOpenAPIDocument(true)
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorByUuid", martinWolfUuid).Return(transformedMartinWolf)
+	mbs.On("getAuthorByUuid", lucyKellawayUuid).Return(person{})
+	mbs.On("getAuthorsUuids").Return(expectedUuids)
+	mbs.On("getAuthorsCount").Return(2)
+	mbs.On("refreshCache").Return(fmt.Errorf(`Bertha says "no"`))
+	mbs.On("getAllAuthors").Return([]person{transformedMartinWolf})
+	mbs.On("getImageByUuid", martinWolfImageSetUuid).Return(imageContents(transformedMartinWolf)[0])
+	mbs.On("getImageStatuses").Return([]imageStatus{{AuthorUuid: martinWolfUuid, ImageUrl: martinWolf.ImageUrl, StatusCode: 404, Broken: true}})
+	mbs.On("getAuditTail", -1).Return([]auditEntry{{TransactionID: "tid_test", Caller: "publishing", SourceURL: "http://bertha", AuthorsBefore: 3, AuthorsAfter: 2,
+		Diff: auditDiff{Removed: 1, RemovedAuthors: []auditAuthor{{UUID: lucyKellawayUuid, PrefLabel: "Lucy Kellaway"}}}}})
+	startCuratedAuthorsTransformer(mbs)
+	defer curatedAuthorsTransformer.Close()
+
+	var tests = []struct {
+		method       string
+		path         string
+		pathTemplate string
+		accept       string
+	}{
+		{"GET", "/transformers/authors/" + martinWolfUuid, "/transformers/authors/{uuid}", ""},
+		{"GET", "/transformers/authors/" + martinWolfUuid, "/transformers/authors/{uuid}", jsonLDMediaType},
+		{"GET", "/transformers/authors/" + lucyKellawayUuid, "/transformers/authors/{uuid}", ""},
+		{"GET", "/v1/transformers/authors/" + martinWolfUuid, "/v1/transformers/authors/{uuid}", ""},
+		{"GET", "/v2/transformers/authors/" + martinWolfUuid, "/v2/transformers/authors/{uuid}", ""},
+		{"GET", "/transformers/authors/__ids", "/transformers/authors/__ids", ""},
+		{"GET", "/transformers/authors/__count", "/transformers/authors/__count", ""},
+		{"POST", "/transformers/authors", "/transformers/authors", ""},
+		{"GET", "/transformers/authors/__images", "/transformers/authors/__images", ""},
+		{"GET", "/transformers/authors/__audit", "/transformers/authors/__audit", ""},
+		{"GET", "/transformers/authors/__export.csv", "/transformers/authors/__export.csv", ""},
+		{"GET", "/transformers/authors/__export.ttl", "/transformers/authors/__export.ttl", ""},
+		{"GET", "/transformers/authors/__export.nt", "/transformers/authors/__export.nt", ""},
+		{"GET", "/transformers/author-images/" + martinWolfImageSetUuid, "/transformers/author-images/{uuid}", ""},
+		{"GET", "/v2/transformers/author-images/" + martinWolfImageSetUuid, "/v2/transformers/author-images/{uuid}", ""},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, curatedAuthorsTransformer.URL+test.path, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		assertMatchesSpec(t, doc, test.pathTemplate, test.method, resp)
+		resp.Body.Close()
+	}
+}
+
+func TestReadmeExampleShouldMatchSpecification(t *testing.T) {
+	readme, err := ioutil.ReadFile("README.md")
+	if err != nil {
+		t.Fatalf("Cannot read the README: %v", err)
+	}
+
+	sections := strings.SplitN(string(readme), "##Authors by UUID", 2)
+	if len(sections) < 2 {
+		t.Fatalf("The README should have an \"##Authors by UUID\" section")
+	}
+	blocks := strings.SplitN(sections[1], "```", 3)
+	if len(blocks) < 3 {
+		t.Fatalf("The \"##Authors by UUID\" section of the README should have a code block with an example author")
+	}
+	example := blocks[1]
+
+	var value interface{}
+	assert.Nil(t, json.Unmarshal([]byte(example), &value), "The README example should be JSON")
+	doc := newOpenAPIDocument(false)
+	assert.Empty(t, validateSchema(doc, refSchema("Person"), value, "$"), "The README example should match the specification")
+}
+
+func TestShouldReportDifferencesWithSpecification(t *testing.T) {
+	doc := newOpenAPIDocument(false)
+	var value interface{}
+	json.Unmarshal([]byte(`{"uuid": 1, "alternativeIdentifiers": {"uuids": null}, "linekdinProfile": "martin-wolf"}`), &value)
+
+	errs := validateSchema(doc, refSchema("Person"), value, "$")
+
+	assert.Equal(t, []string{"$.linekdinProfile is not in the specification", "$.prefLabel is required", "$.uuid should be a string"}, errs)
+}
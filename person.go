@@ -1,23 +1,41 @@
 package main
 
+// This struct is the internal model of a person shared by all the API versions.
+// Its JSON tags define the v1 representation, kept as it is for existing UP ingesters.
 type person struct {
-	Uuid                   string                 `json:"uuid"`
-	BirthYear              int                    `json:"birthYear,omitempty"`
-	AlternativeIdentifiers alternativeIdentifiers `json:"alternativeIdentifiers"`
-	Name                   string                 `json:"name,omitempty"`
-	PrefLabel              string                 `json:"prefLabel"`
-	Salutation             string                 `json:"salutation,omitempty"`
-	Aliases                []string               `json:"aliases,omitempty"`
-	EmailAddress           string                 `json:"emailAddress,omitempty"`
-	TwitterHandle          string                 `json:"twitterHandle,omitempty"`
-	FacebookProfile        string                 `json:"facebookProfile,omitempty"`
-	LinkedinProfile        string                 `json:"linkedinProfile,omitempty"`
-	Description            string                 `json:"description,omitempty"`
-	DescriptionXML         string                 `json:"descriptionXML,omitempty"`
-	ImageUrl               string                 `json:"_imageUrl,omitempty"` // TODO this is a temporary thing - needs to be integrated into images properly
+	Uuid                   string                          `json:"uuid"`
+	BirthYear              int                             `json:"birthYear,omitempty"`
+	AlternativeIdentifiers alternativeIdentifiers          `json:"alternativeIdentifiers"`
+	Name                   string                          `json:"name,omitempty"`
+	PrefLabel              string                          `json:"prefLabel"`
+	Salutation             string                          `json:"salutation,omitempty"`
+	GivenName              string                          `json:"givenName,omitempty"`
+	FamilyName             string                          `json:"familyName,omitempty"`
+	SortKey                string                          `json:"sortKey,omitempty"`
+	Aliases                []string                        `json:"aliases,omitempty"`
+	Role                   string                          `json:"role,omitempty"`
+	EmailAddress           string                          `json:"emailAddress,omitempty"`
+	TwitterHandle          string                          `json:"twitterHandle,omitempty"`
+	FacebookProfile        string                          `json:"facebookProfile,omitempty"`
+	LinkedinProfile        string                          `json:"linkedinProfile,omitempty"`
+	Description            string                          `json:"description,omitempty"`
+	DescriptionXML         string                          `json:"descriptionXML,omitempty"`
+	PlainDescription       string                          `json:"plainDescription,omitempty"`
+	Summary                string                          `json:"summary,omitempty"`
+	LocalisedDescriptions  map[string]localisedDescription `json:"-"`
+	ImageUrl               string                          `json:"_imageUrl,omitempty"` // Deprecated: kept for existing consumers, use ImageSet instead
+	ImageSet               *imageSet                       `json:"imageSet,omitempty"`
 }
 
 type alternativeIdentifiers struct {
 	TME   []string `json:"TME,omitempty"`
 	UUIDS []string `json:"uuids"`
 }
+
+// This struct holds the description of a person in a language other than the default one
+type localisedDescription struct {
+	Description      string
+	DescriptionXML   string
+	PlainDescription string
+	Summary          string
+}
@@ -0,0 +1,75 @@
+package main
+
+// personSerializer turns the internal model of a person into the representation of an API version
+type personSerializer func(person) interface{}
+
+func serializePersonV1(p person) interface{} {
+	return p
+}
+
+func serializePersonV2(p person) interface{} {
+	return toPersonV2(p)
+}
+
+// This struct reflects the v2 representation of a person, without the legacy quirks of v1:
+// no duplicated name, no underscore image URL, no zero birth year and no empty identifier lists
+type personV2 struct {
+	UUID             string        `json:"uuid"`
+	PrefLabel        string        `json:"prefLabel"`
+	Identifiers      identifiersV2 `json:"identifiers"`
+	BirthYear        *int          `json:"birthYear,omitempty"`
+	Salutation       string        `json:"salutation,omitempty"`
+	GivenName        string        `json:"givenName,omitempty"`
+	FamilyName       string        `json:"familyName,omitempty"`
+	SortKey          string        `json:"sortKey,omitempty"`
+	Aliases          []string      `json:"aliases,omitempty"`
+	Role             string        `json:"role,omitempty"`
+	EmailAddress     string        `json:"emailAddress,omitempty"`
+	TwitterHandle    string        `json:"twitterHandle,omitempty"`
+	FacebookProfile  string        `json:"facebookProfile,omitempty"`
+	LinkedinProfile  string        `json:"linkedinProfile,omitempty"`
+	Description      string        `json:"description,omitempty"`
+	DescriptionXML   string        `json:"descriptionXML,omitempty"`
+	PlainDescription string        `json:"plainDescription,omitempty"`
+	Summary          string        `json:"summary,omitempty"`
+	ImageSet         *imageSet     `json:"imageSet,omitempty"`
+}
+
+type identifiersV2 struct {
+	UUIDs []string `json:"uuids,omitempty"`
+	TME   []string `json:"tme,omitempty"`
+}
+
+func toPersonV2(p person) personV2 {
+	v2 := personV2{
+		UUID:      p.Uuid,
+		PrefLabel: p.PrefLabel,
+		Identifiers: identifiersV2{
+			UUIDs: p.AlternativeIdentifiers.UUIDS,
+			TME:   p.AlternativeIdentifiers.TME,
+		},
+		Salutation:       p.Salutation,
+		GivenName:        p.GivenName,
+		FamilyName:       p.FamilyName,
+		SortKey:          p.SortKey,
+		Aliases:          p.Aliases,
+		Role:             p.Role,
+		EmailAddress:     p.EmailAddress,
+		TwitterHandle:    p.TwitterHandle,
+		FacebookProfile:  p.FacebookProfile,
+		LinkedinProfile:  p.LinkedinProfile,
+		Description:      p.Description,
+		DescriptionXML:   p.DescriptionXML,
+		PlainDescription: p.PlainDescription,
+		Summary:          p.Summary,
+		ImageSet:         p.ImageSet,
+	}
+	if v2.PrefLabel == "" {
+		v2.PrefLabel = p.Name
+	}
+	if p.BirthYear != 0 {
+		birthYear := p.BirthYear
+		v2.BirthYear = &birthYear
+	}
+	return v2
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldConvertPersonToV2(t *testing.T) {
+	p := transformedMartinWolf
+	p.BirthYear = 1946
+	p.AlternativeIdentifiers = alternativeIdentifiers{}
+
+	v2 := toPersonV2(p)
+
+	assert.Equal(t, martinWolfUuid, v2.UUID)
+	assert.Equal(t, "Martin Wolf", v2.PrefLabel)
+	assert.Equal(t, 1946, *v2.BirthYear, "The birth year should be set")
+	assert.Equal(t, identifiersV2{}, v2.Identifiers, "Empty identifiers should stay empty")
+	assert.Equal(t, martinWolfImageSet, v2.ImageSet)
+}
+
+func TestShouldLeaveUnknownBirthYearOutOfV2(t *testing.T) {
+	assert.Nil(t, toPersonV2(transformedMartinWolf).BirthYear, "A zero birth year should be unknown")
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	turtleMediaType   = "text/turtle"
+	nTriplesMediaType = "application/n-triples"
+	rdfNamespace      = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	foafNamespace     = "http://xmlns.com/foaf/0.1/"
+	schemaNamespace   = "http://schema.org/"
+	owlNamespace      = "http://www.w3.org/2002/07/owl#"
+	tmeIdentifierPath = tmeAuthority + "/"
+)
+
+// Prefixes used in Turtle output, in order of declaration
+var rdfPrefixes = []struct {
+	prefix    string
+	namespace string
+}{
+	{"rdf", rdfNamespace},
+	{"foaf", foafNamespace},
+	{"schema", schemaNamespace},
+	{"owl", owlNamespace},
+}
+
+type rdfTerm struct {
+	value   string
+	literal bool
+	lang    string
+}
+
+type triple struct {
+	subject   string
+	predicate string
+	object    rdfTerm
+}
+
+func iri(value string) rdfTerm {
+	return rdfTerm{value: value}
+}
+
+func literal(value string) rdfTerm {
+	return rdfTerm{value: value, literal: true}
+}
+
+// personToTriples describes a person with FOAF and schema.org, linking its alternative UUIDs and TME identifiers with owl:sameAs
+func personToTriples(p person) []triple {
+	subject := thingsBaseUrl + p.Uuid
+	var triples []triple
+	add := func(predicate string, object rdfTerm) {
+		if object.value != "" {
+			triples = append(triples, triple{subject, predicate, object})
+		}
+	}
+
+	add(rdfNamespace+"type", iri(foafNamespace+"Person"))
+	add(rdfNamespace+"type", iri(schemaNamespace+"Person"))
+	add(foafNamespace+"name", literal(p.PrefLabel))
+	add(foafNamespace+"title", literal(p.Salutation))
+	add(foafNamespace+"givenName", literal(p.GivenName))
+	add(foafNamespace+"familyName", literal(p.FamilyName))
+	for _, alias := range p.Aliases {
+		add(schemaNamespace+"alternateName", literal(alias))
+	}
+	add(schemaNamespace+"jobTitle", literal(p.Role))
+	add(schemaNamespace+"description", literal(strings.TrimSpace(p.Description)))
+	for _, lang := range sortedLanguages(p.LocalisedDescriptions) {
+		d := p.LocalisedDescriptions[lang]
+		add(schemaNamespace+"description", rdfTerm{value: strings.TrimSpace(d.Description), literal: true, lang: languageTag(lang)})
+	}
+	if p.ImageUrl != "" {
+		add(foafNamespace+"depiction", iri(p.ImageUrl))
+	}
+	for _, profile := range toSchemaPerson(p).SameAs {
+		add(schemaNamespace+"sameAs", iri(profile))
+	}
+	for _, uuid := range p.AlternativeIdentifiers.UUIDS {
+		if uuid != p.Uuid {
+			add(owlNamespace+"sameAs", iri(thingsBaseUrl+uuid))
+		}
+	}
+	for _, tme := range p.AlternativeIdentifiers.TME {
+		add(owlNamespace+"sameAs", iri(tmeIdentifierPath+tme))
+	}
+	return triples
+}
+
+func writeNTriples(buf *bytes.Buffer, people []person) {
+	for _, p := range people {
+		for _, t := range personToTriples(p) {
+			fmt.Fprintf(buf, "<%s> <%s> %s .\n", escapeIRI(t.subject), escapeIRI(t.predicate), formatTerm(t.object, false))
+		}
+	}
+}
+
+func writeTurtle(buf *bytes.Buffer, people []person) {
+	for _, prefix := range rdfPrefixes {
+		fmt.Fprintf(buf, "@prefix %s: <%s> .\n", prefix.prefix, prefix.namespace)
+	}
+	for _, p := range people {
+		triples := personToTriples(p)
+		fmt.Fprintf(buf, "\n<%s>", escapeIRI(thingsBaseUrl+p.Uuid))
+		for i, t := range triples {
+			if i > 0 {
+				buf.WriteString(" ;")
+			}
+			fmt.Fprintf(buf, "\n    %s %s", compactIRI(t.predicate), formatTerm(t.object, true))
+		}
+		buf.WriteString(" .\n")
+	}
+}
+
+func formatTerm(term rdfTerm, compact bool) string {
+	if !term.literal {
+		if compact {
+			return compactIRI(term.value)
+		}
+		return "<" + escapeIRI(term.value) + ">"
+	}
+	s := `"` + escapeLiteral(term.value) + `"`
+	if term.lang != "" {
+		s += "@" + term.lang
+	}
+	return s
+}
+
+// compactIRI uses the Turtle prefixes for IRIs in the known namespaces whose local name needs no escaping
+func compactIRI(value string) string {
+	for _, prefix := range rdfPrefixes {
+		local := strings.TrimPrefix(value, prefix.namespace)
+		if local != value && isSimpleLocalName(local) {
+			return prefix.prefix + ":" + local
+		}
+	}
+	return "<" + escapeIRI(value) + ">"
+}
+
+func isSimpleLocalName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+var literalEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+func escapeLiteral(value string) string {
+	return literalEscaper.Replace(value)
+}
+
+// escapeIRI percent encodes the characters that are not allowed in IRIs
+func escapeIRI(value string) string {
+	var buf bytes.Buffer
+	for _, r := range value {
+		if r <= 0x20 || strings.ContainsRune(`<>"{}|^`+"`"+`\`, r) {
+			fmt.Fprintf(&buf, "%%%02X", r)
+		} else {
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+func sortedLanguages(descriptions map[string]localisedDescription) []string {
+	var langs []string
+	for lang := range descriptions {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
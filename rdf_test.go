@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldLinkAlternativeIdentifiersWithSameAs(t *testing.T) {
+	p := transformedMartinWolf
+	p.AlternativeIdentifiers = alternativeIdentifiers{
+		UUIDS: []string{martinWolfUuid, lucyKellawayUuid},
+		TME:   martinWolfAltIds.TME,
+	}
+
+	triples := personToTriples(p)
+
+	assert.Contains(t, triples, triple{thingsBaseUrl + martinWolfUuid, owlNamespace + "sameAs", iri(thingsBaseUrl + lucyKellawayUuid)}, "Alternative UUIDs should be linked")
+	assert.NotContains(t, triples, triple{thingsBaseUrl + martinWolfUuid, owlNamespace + "sameAs", iri(thingsBaseUrl + martinWolfUuid)}, "The canonical UUID should not be linked to itself")
+}
+
+func TestShouldWriteLocalisedDescriptionsWithLanguageTags(t *testing.T) {
+	p := person{
+		Uuid:                  lucyKellawayUuid,
+		PrefLabel:             "Lucy Kellaway",
+		LocalisedDescriptions: map[string]localisedDescription{"zh": lucyKellawayZh},
+	}
+
+	var buf bytes.Buffer
+	writeNTriples(&buf, []person{p})
+
+	assert.Contains(t, buf.String(), `<http://schema.org/description> "`+lucyKellawayZh.Description+`"@zh .`)
+}
+
+func TestShouldWriteValidLanguageTags(t *testing.T) {
+	p := person{
+		Uuid:                  lucyKellawayUuid,
+		PrefLabel:             "Lucy Kellaway",
+		LocalisedDescriptions: map[string]localisedDescription{"zh_hans": lucyKellawayZh},
+	}
+
+	var buf bytes.Buffer
+	writeNTriples(&buf, []person{p})
+
+	assert.Contains(t, buf.String(), `"@zh-hans .`, "Underscores should not be part of the language tags")
+}
+
+func TestShouldEscapeLiteralsAndIRIs(t *testing.T) {
+	p := person{
+		Uuid:      lucyKellawayUuid,
+		PrefLabel: "Lucy \"The Office\" Kellaway\n",
+		ImageUrl:  "https://example.com/lucy kellaway.png",
+	}
+
+	var buf bytes.Buffer
+	writeTurtle(&buf, []person{p})
+	output := buf.String()
+
+	assert.True(t, strings.Contains(output, `foaf:name "Lucy \"The Office\" Kellaway\n"`), "Quotes and new lines should be escaped")
+	assert.True(t, strings.Contains(output, `foaf:depiction <https://example.com/lucy%20kellaway.png>`), "Spaces should be percent encoded")
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+const (
+	tracingExporterNone   = "none"
+	tracingExporterStdout = "stdout"
+	tracingExporterOTLP   = "otlp"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	serviceName       = "curated-authors-transformer"
+)
+
+type contextKey int
+
+const (
+	spanContextKey contextKey = iota
+	transactionIDContextKey
+	callerContextKey
+)
+
+// spanExporter sends the ended spans to a tracing backend
+type spanExporter interface {
+	exportSpan(s *span)
+	shutdown()
+}
+
+// The exporter of the spans of the service, spans are not recorded when it is nil
+var tracingExporter spanExporter
+
+// newSpanExporter creates the exporter of a kind, nil when tracing is disabled
+func newSpanExporter(kind string, otlpEndpoint string) (spanExporter, error) {
+	switch kind {
+	case tracingExporterNone:
+		return nil, nil
+	case tracingExporterStdout:
+		return &writerExporter{w: os.Stdout}, nil
+	case tracingExporterOTLP:
+		return newOTLPExporter(otlpEndpoint, 512, 5*time.Second), nil
+	}
+	return nil, fmt.Errorf("Unsupported tracing exporter: %s", kind)
+}
+
+// span is a timed operation of a trace, in the spirit of OpenTelemetry
+type span struct {
+	name         string
+	traceID      string
+	spanID       string
+	parentSpanID string
+	start        time.Time
+	end          time.Time
+	attributes   map[string]interface{}
+	err          error
+	mutex        sync.Mutex
+}
+
+// startSpan starts a child of the span of the context, or a new trace when there is none
+func startSpan(ctx context.Context, name string) (context.Context, *span) {
+	s := &span{name: name, spanID: randomHex(8), start: time.Now(), attributes: map[string]interface{}{}}
+	if parent, ok := ctx.Value(spanContextKey).(*span); ok {
+		s.traceID = parent.traceID
+		s.parentSpanID = parent.spanID
+	} else {
+		s.traceID = randomHex(16)
+	}
+	if tid := transactionID(ctx); tid != "" {
+		s.attributes["transaction_id"] = tid
+	}
+	return context.WithValue(ctx, spanContextKey, s), s
+}
+
+func (s *span) setAttribute(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attributes[key] = value
+}
+
+// finish ends the span with the outcome of its operation and exports it
+func (s *span) finish(err error) {
+	s.mutex.Lock()
+	s.end = time.Now()
+	s.err = err
+	s.mutex.Unlock()
+	if tracingExporter != nil {
+		tracingExporter.exportSpan(s)
+	}
+}
+
+// traceparent is the W3C Trace Context header propagating the span to another service
+func (s *span) traceparent() string {
+	return "00-" + s.traceID + "-" + s.spanID + "-01"
+}
+
+// parseTraceparent returns a remote parent span, nil when the header is missing or malformed
+func parseTraceparent(header string) *span {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return nil
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return nil
+	}
+	return &span{traceID: strings.ToLower(parts[1]), spanID: strings.ToLower(parts[2])}
+}
+
+func withTransactionID(ctx context.Context, tid string) context.Context {
+	return context.WithValue(ctx, transactionIDContextKey, tid)
+}
+
+func transactionID(ctx context.Context) string {
+	tid, _ := ctx.Value(transactionIDContextKey).(string)
+	return tid
+}
+
+func newTransactionID() string {
+	return "tid_" + randomHex(5)
+}
+
+// tracingHandler starts a span for every request, continuing the trace of the caller when there is a traceparent header,
+// and keeps the transaction id of the request in its context. A request without transaction id gets the one the request
+// logging handler returns in the response header, so that the access log, the trace and the audit log agree.
+func tracingHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if parent := parseTraceparent(req.Header.Get(traceparentHeader)); parent != nil {
+			ctx = context.WithValue(ctx, spanContextKey, parent)
+		}
+		tid := req.Header.Get(transactionIDHeader)
+		if tid == "" {
+			tid = writer.Header().Get(transactionIDHeader)
+		}
+		if tid == "" {
+			tid = newTransactionID()
+			writer.Header().Set(transactionIDHeader, tid)
+		}
+		ctx, s := startSpan(withTransactionID(ctx, tid), spanName(h, req))
+		s.setAttribute("http.method", req.Method)
+		s.setAttribute("http.target", req.URL.RequestURI())
+
+		rec := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		h.ServeHTTP(rec, req.WithContext(ctx))
+
+		s.setAttribute("http.status_code", rec.status)
+		var err error
+		if rec.status >= http.StatusInternalServerError {
+			err = fmt.Errorf("HTTP status %d", rec.status)
+		}
+		s.finish(err)
+	})
+}
+
+// spanName names the span of a request after the template of its route, like GET /transformers/authors/{uuid},
+// so that the number of span names does not grow with the requested authors
+func spanName(h http.Handler, req *http.Request) string {
+	if r, ok := h.(*mux.Router); ok {
+		var match mux.RouteMatch
+		if r.Match(req, &match) && match.Route != nil {
+			if template, err := match.Route.GetPathTemplate(); err == nil {
+				return req.Method + " " + template
+			}
+		}
+	}
+	return req.Method
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// injectTracing propagates the transaction id and the span of the context to an outbound request
+func injectTracing(ctx context.Context, req *http.Request) {
+	if tid := transactionID(ctx); tid != "" {
+		req.Header.Set(transactionIDHeader, tid)
+	}
+	if s, ok := ctx.Value(spanContextKey).(*span); ok {
+		req.Header.Set(traceparentHeader, s.traceparent())
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Errorf("Cannot generate random id: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// writerExporter writes every span as a JSON line
+type writerExporter struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+func (e *writerExporter) exportSpan(s *span) {
+	line, err := json.Marshal(toOTLPSpan(s))
+	if err != nil {
+		log.Errorf("Cannot encode span %s: %v", s.name, err)
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.w.Write(append(line, '\n'))
+}
+
+func (e *writerExporter) shutdown() {}
+
+// otlpExporter sends the spans in batches to an OpenTelemetry collector with OTLP/HTTP in JSON
+type otlpExporter struct {
+	endpoint      string
+	client        *http.Client
+	spans         chan *span
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+	closed        bool
+	mutex         sync.Mutex
+}
+
+func newOTLPExporter(endpoint string, batchSize int, flushInterval time.Duration) *otlpExporter {
+	e := &otlpExporter{
+		endpoint:      endpoint,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		spans:         make(chan *span, 4*batchSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// exportSpan queues the span, dropping it when the collector cannot keep up or the exporter is shut down
+func (e *otlpExporter) exportSpan(s *span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.closed {
+		log.Warnf("Dropped span %s, the tracing exporter is shut down", s.name)
+		return
+	}
+	select {
+	case e.spans <- s:
+	default:
+		log.Warnf("Dropped span %s, the tracing queue is full", s.name)
+	}
+}
+
+// shutdown sends the queued spans. The spans ending afterwards are dropped.
+func (e *otlpExporter) shutdown() {
+	e.mutex.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.spans)
+	}
+	e.mutex.Unlock()
+	<-e.done
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	var batch []*span
+	for {
+		select {
+		case s, ok := <-e.spans:
+			if !ok {
+				e.send(batch)
+				return
+			}
+			if batch = append(batch, s); len(batch) >= e.batchSize {
+				e.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.send(batch)
+			batch = nil
+		}
+	}
+}
+
+func (e *otlpExporter) send(batch []*span) {
+	if len(batch) == 0 {
+		return
+	}
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = toOTLPSpan(s)
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{toOTLPAttribute("service.name", serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: serviceName}, Spans: spans}},
+	}}})
+	if err != nil {
+		log.Errorf("Cannot encode spans: %v", err)
+		return
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Warnf("Cannot send %d spans to %s: %v", len(batch), e.endpoint, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Warnf("Cannot send %d spans to %s: HTTP status %d", len(batch), e.endpoint, resp.StatusCode)
+	}
+}
+
+// These structs are the JSON encoding of the OTLP trace export request
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+func toOTLPSpan(s *span) otlpSpan {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	o := otlpSpan{
+		TraceID:           s.traceID,
+		SpanID:            s.spanID,
+		ParentSpanID:      s.parentSpanID,
+		Name:              s.name,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: otlpStatusOK},
+	}
+	for _, k := range sortedAttributeKeys(s.attributes) {
+		o.Attributes = append(o.Attributes, toOTLPAttribute(k, s.attributes[k]))
+	}
+	if s.err != nil {
+		o.Status = otlpStatus{Code: otlpStatusError, Message: s.err.Error()}
+	}
+	return o
+}
+
+func toOTLPAttribute(key string, value interface{}) otlpAttribute {
+	var v otlpAttributeValue
+	switch t := value.(type) {
+	case int:
+		i := strconv.Itoa(t)
+		v.IntValue = &i
+	case bool:
+		v.BoolValue = &t
+	default:
+		str := fmt.Sprint(t)
+		v.StringValue = &str
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
+
+func sortedAttributeKeys(attributes map[string]interface{}) []string {
+	keys := map[string]bool{}
+	for k := range attributes {
+		keys[k] = true
+	}
+	return sortedKeys(keys)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingExporter struct {
+	spans []*span
+	mutex sync.Mutex
+}
+
+func (e *recordingExporter) exportSpan(s *span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func (e *recordingExporter) shutdown() {}
+
+// spanNamed returns the last exported span with a name
+func (e *recordingExporter) spanNamed(name string) *span {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for i := len(e.spans) - 1; i >= 0; i-- {
+		if e.spans[i].name == name {
+			return e.spans[i]
+		}
+	}
+	return nil
+}
+
+func TestShouldPropagateTraceToBertha(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracingExporter = exporter
+	defer func() { tracingExporter = nil }()
+
+	var berthaHeaders http.Header
+	bertha := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		berthaHeaders = r.Header
+		berthaHandlerMock(w, r)
+	}))
+	defer bertha.Close()
+	bs, err := newBerthaService(bertha.URL, &berthaTransformer{}, nil, 0, 0)
+	assert.Nil(t, err)
+
+	server := httptest.NewServer(tracingHandler(setupServiceHandlers(newAuthorHandler(bs, nil, healthConfig{}), false, nil)))
+	defer server.Close()
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest("POST", server.URL+"/transformers/authors", nil)
+	req.Header.Set(transactionIDHeader, "tid_refresh")
+	req.Header.Set(traceparentHeader, "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "tid_refresh", berthaHeaders.Get(transactionIDHeader), "The transaction id should be sent to Bertha")
+	assert.True(t, strings.HasPrefix(berthaHeaders.Get(traceparentHeader), "00-"+traceID+"-"), "The trace should be propagated to Bertha")
+
+	inbound := exporter.spanNamed("POST /transformers/authors")
+	refresh := exporter.spanNamed("refreshAuthors")
+	transform := exporter.spanNamed("authorToPerson")
+	assert.NotNil(t, inbound, "The inbound request should be traced")
+	assert.NotNil(t, refresh, "The refresh should be traced")
+	assert.NotNil(t, transform, "The transformation should be traced")
+	assert.Equal(t, "00f067aa0ba902b7", inbound.parentSpanID, "The inbound span should continue the trace of the caller")
+	assert.Equal(t, traceID, transform.traceID, "The transformation should be part of the trace")
+	assert.Equal(t, 2, refresh.attributes["authors.fetched"], "The span should be tagged with the fetched authors")
+	assert.Equal(t, 2, refresh.attributes["authors.cached"], "The span should be tagged with the cached authors")
+}
+
+func TestShouldNameSpansAfterRoutesAndReuseTransactionID(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracingExporter = exporter
+	defer func() { tracingExporter = nil }()
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorByUuid", martinWolfUuid).Return(person{})
+	h := tracingHandler(setupServiceHandlers(newAuthorHandler(mbs, nil, healthConfig{}), false, nil))
+	logged := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(transactionIDHeader, "tid_logged")
+		h.ServeHTTP(w, req)
+	})
+
+	w := httptest.NewRecorder()
+	logged(w, httptest.NewRequest("GET", "/transformers/authors/"+martinWolfUuid, nil))
+
+	s := exporter.spanNamed("GET /transformers/authors/{uuid}")
+	assert.NotNil(t, s, "The span should be named after the route template")
+	assert.Equal(t, "tid_logged", s.attributes["transaction_id"], "The transaction id of the request logging should be reused")
+	assert.Equal(t, "tid_logged", w.Header().Get(transactionIDHeader))
+}
+
+func TestShouldIgnoreMalformedTraceparent(t *testing.T) {
+	for _, header := range []string{"", "00-xyz-00f067aa0ba902b7-01", "00-4bf92f3577b34da6a3ce929d0e0e4736-01", "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"} {
+		assert.Nil(t, parseTraceparent(header), "Header %q should be ignored", header)
+	}
+}
+
+func TestShouldExportSpansWithOTLP(t *testing.T) {
+	var received otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"), "Spans should be sent as JSON")
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer collector.Close()
+
+	exporter := newOTLPExporter(collector.URL, 10, time.Hour)
+	_, s := startSpan(withTransactionID(context.Background(), "tid_test"), "refreshCache")
+	s.setAttribute("authors.fetched", 2)
+	s.end = time.Now()
+	exporter.exportSpan(s)
+	exporter.shutdown()
+
+	assert.Equal(t, 1, len(received.ResourceSpans), "The spans should be sent on shutdown")
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Equal(t, 1, len(spans), "There should be one span")
+	assert.Equal(t, "refreshCache", spans[0].Name, "Unexpected span name")
+	assert.Equal(t, s.traceID, spans[0].TraceID, "Unexpected trace id")
+	assert.Equal(t, "authors.fetched", spans[0].Attributes[0].Key, "Unexpected attribute")
+	assert.Equal(t, "2", *spans[0].Attributes[0].Value.IntValue, "Attributes should keep their type")
+	assert.Equal(t, "tid_test", *spans[0].Attributes[1].Value.StringValue, "The transaction id should be recorded")
+}
+
+func TestShouldDropSpansAfterShutdown(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer collector.Close()
+	exporter := newOTLPExporter(collector.URL, 10, time.Hour)
+	exporter.shutdown()
+
+	_, s := startSpan(context.Background(), "checkConnectivity")
+	s.end = time.Now()
+	assert.NotPanics(t, func() { exporter.exportSpan(s) }, "A span ending after the shutdown should be dropped")
+	assert.NotPanics(t, exporter.shutdown, "The exporter should be shut down only once")
+}
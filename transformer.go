@@ -1,5 +1,7 @@
 package main
 
+import "context"
+
 type transformer interface {
-	authorToPerson(author) (person, error)
+	authorToPerson(ctx context.Context, a author) (person, error)
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Clears the restricted fields of a person, by name of the field in the JSON representation
+var redactors = map[string]func(p *person){
+	"emailAddress":    func(p *person) { p.EmailAddress = "" },
+	"twitterHandle":   func(p *person) { p.TwitterHandle = "" },
+	"facebookProfile": func(p *person) { p.FacebookProfile = "" },
+	"linkedinProfile": func(p *person) { p.LinkedinProfile = "" },
+}
+
+// visibilityPolicy leaves the restricted fields out of the authors, unless the request is authenticated
+// with one of the keys granted access to personal data
+type visibilityPolicy struct {
+	restrictedFields []string
+	auth             authenticator
+	privilegedKeys   map[string]bool
+}
+
+// newVisibilityPolicy creates the policy from comma separated lists of field and key names
+func newVisibilityPolicy(restrictedFields string, auth authenticator, privilegedKeys string) (*visibilityPolicy, error) {
+	vp := &visibilityPolicy{auth: auth, privilegedKeys: map[string]bool{}}
+	for _, f := range splitList(restrictedFields) {
+		if _, found := redactors[f]; !found {
+			return nil, fmt.Errorf("Unsupported restricted field: %s", f)
+		}
+		vp.restrictedFields = append(vp.restrictedFields, f)
+	}
+	for _, k := range splitList(privilegedKeys) {
+		vp.privilegedKeys[k] = true
+	}
+	return vp, nil
+}
+
+// canSeeRestrictedFields is true when the request is authenticated with a privileged key.
+// A nil policy restricts nothing.
+func (vp *visibilityPolicy) canSeeRestrictedFields(req *http.Request) bool {
+	if vp == nil || len(vp.restrictedFields) == 0 {
+		return true
+	}
+	if vp.auth == nil {
+		return false
+	}
+	key, err := vp.auth.authenticate(req)
+	return err == nil && vp.privilegedKeys[key]
+}
+
+// redact returns the authors without the restricted fields the request is not allowed to see
+func (vp *visibilityPolicy) redact(req *http.Request, authors ...person) []person {
+	if vp.canSeeRestrictedFields(req) {
+		return authors
+	}
+	redacted := make([]person, len(authors))
+	for i, p := range authors {
+		for _, f := range vp.restrictedFields {
+			redactors[f](&p)
+		}
+		redacted[i] = p
+	}
+	return redacted
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, i := range strings.Split(list, ",") {
+		if i = strings.TrimSpace(i); i != "" {
+			items = append(items, i)
+		}
+	}
+	return items
+}
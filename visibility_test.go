@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldRedactRestrictedFieldsForAnonymousCallers(t *testing.T) {
+	a, _ := newAuthenticator(authModeAPIKey, authKeys)
+	vp, err := newVisibilityPolicy("emailAddress, twitterHandle", a, "publishing")
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest("GET", "/transformers/authors/"+martinWolfUuid, nil)
+	redacted := vp.redact(req, transformedMartinWolf)[0]
+
+	assert.Empty(t, redacted.EmailAddress, "The email address should be redacted")
+	assert.Empty(t, redacted.TwitterHandle, "The twitter handle should be redacted")
+	assert.Equal(t, transformedMartinWolf.PrefLabel, redacted.PrefLabel, "Other fields should be left as they are")
+	assert.NotEmpty(t, transformedMartinWolf.EmailAddress, "The cached author should not be modified")
+}
+
+func TestShouldReturnRestrictedFieldsToPrivilegedKeys(t *testing.T) {
+	a, _ := newAuthenticator(authModeAPIKey, authKeys)
+	vp, _ := newVisibilityPolicy("emailAddress", a, "publishing")
+
+	var tests = []struct {
+		apiKey  string
+		visible bool
+	}{
+		{"s3cr3t", true},
+		{"0th3r", false},
+		{"guess", false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/transformers/authors/"+martinWolfUuid, nil)
+		req.Header.Set(apiKeyHeader, test.apiKey)
+		assert.Equal(t, test.visible, vp.canSeeRestrictedFields(req), "Unexpected visibility for key %s", test.apiKey)
+	}
+}
+
+func TestShouldRejectUnsupportedRestrictedFields(t *testing.T) {
+	_, err := newVisibilityPolicy("emailAddress,prefLabel", nil, "")
+	assert.NotNil(t, err, "Only personal data fields can be restricted")
+}
+
+func TestShouldNotReturnEmailToAnonymousCallers(t *testing.T) {
+	a, _ := newAuthenticator(authModeAPIKey, authKeys)
+	vp, _ := newVisibilityPolicy("emailAddress", a, "publishing")
+	mbs := new(MockedBerthaService)
+	mbs.On("getAuthorByUuid", martinWolfUuid).Return(transformedMartinWolf)
+	mbs.On("getAllAuthors").Return([]person{transformedMartinWolf})
+	server := httptest.NewServer(setupServiceHandlers(newAuthorHandler(mbs, vp, healthConfig{}), false, a))
+	defer server.Close()
+
+	for _, path := range []string{"/transformers/authors/" + martinWolfUuid, "/v2/transformers/authors/" + martinWolfUuid, "/transformers/authors/__export.csv"} {
+		resp, err := http.Get(server.URL + path)
+		assert.Nil(t, err)
+		body := getStringFromReader(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "Response status should be 200 for "+path)
+		assert.Equal(t, "private", resp.Header.Get("Cache-Control"), "Response should not be cached by shared caches for "+path)
+		assert.NotContains(t, body, transformedMartinWolf.EmailAddress, "The email address should be redacted from "+path)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/transformers/authors/"+martinWolfUuid, nil)
+	req.Header.Set(apiKeyHeader, "s3cr3t")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Contains(t, getStringFromReader(resp.Body), transformedMartinWolf.EmailAddress, "The email address should be returned to privileged keys")
+}